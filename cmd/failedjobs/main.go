@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"mailassist/internal/infrastructure/persistence/sqlite"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: failedjobs [-db path] <command>
+
+Commands:
+  list                  show jobs in the dead-letter table
+  requeue <gmail_id>    move one job back into the job queue (any account)
+  requeue all           move every failed job back into the job queue`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	dbPath := flag.String("db", getEnv("DATABASE_PATH", "mailai.db"), "SQLite database path")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	db, err := sqlite.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	repo := sqlite.NewFailedJobRepository(db)
+
+	switch flag.Arg(0) {
+	case "list":
+		jobs, err := repo.ListFailedJobs(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list jobs: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tGMAIL ID\tATTEMPTS\tTRANSIENT\tFAILED AT\tLAST ERROR")
+		for _, j := range jobs {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%t\t%s\t%s\n",
+				j.Account, j.GmailID, j.Attempts, j.Transient, j.FailedAt.Format(time.RFC3339), j.LastError)
+		}
+		w.Flush()
+
+	case "requeue":
+		if flag.NArg() != 2 {
+			usage()
+			os.Exit(2)
+		}
+
+		id := flag.Arg(1)
+		if id == "all" {
+			id = ""
+		}
+
+		n, err := repo.Requeue(ctx, id)
+		if err != nil {
+			log.Fatalf("Failed to requeue: %v", err)
+		}
+		fmt.Printf("%d job(s) moved back to the job queue\n", n)
+
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"mailassist/internal/infrastructure/persistence/sqlite"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: history [-db path] [-account id] <gmail_id>
+
+Shows every classification recorded for an email, oldest first.`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	dbPath := flag.String("db", getEnv("DATABASE_PATH", "mailai.db"), "SQLite database path")
+	account := flag.String("account", "me", "mailbox the email belongs to")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	db, err := sqlite.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	repo := sqlite.NewClassificationRepository(db).ForAccount(*account)
+
+	history, err := repo.History(ctx, flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to load history: %v", err)
+	}
+	if len(history) == 0 {
+		fmt.Printf("No classifications recorded for %s\n", flag.Arg(0))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CREATED AT\tSOURCE\tCATEGORY\tLABEL\tMODEL\tPROMPT\tLATENCY\tTOKENS IN/OUT")
+	for _, rec := range history {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\n",
+			rec.CreatedAt.Format(time.RFC3339), rec.Source, rec.Category, rec.Label,
+			rec.Model, rec.PromptVersion, rec.Latency, rec.InputTokens, rec.OutputTokens)
+	}
+	w.Flush()
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"mailassist/internal/infrastructure/persistence/sqlite"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: importlegacy [-db path] [-dry-run]
+
+Converts a database created by the legacy store package to the current
+schema. Emails are kept, stored drafts move to their own table. Rows that
+cannot be converted are listed and stay in the legacy_emails table.`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	dbPath := flag.String("db", getEnv("DATABASE_PATH", "mailai.db"), "SQLite database path")
+	dryRun := flag.Bool("dry-run", false, "report what would be imported without writing")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	report, err := sqlite.ImportLegacyStore(context.Background(), *dbPath, *dryRun)
+	if err != nil {
+		log.Fatalf("Failed to import %s: %v", *dbPath, err)
+	}
+
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d email(s) and %d draft(s)\n", verb, report.Emails, report.Drafts)
+
+	if len(report.Skipped) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d row(s) could not be converted and stay in legacy_emails:\n", len(report.Skipped))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tGMAIL ID\tREASON")
+	for _, row := range report.Skipped {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", row.ID, row.GmailID, row.Reason)
+	}
+	w.Flush()
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -2,114 +2,369 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"mailassist/internal/application/email"
 	"mailassist/internal/infrastructure/config"
 	"mailassist/internal/infrastructure/gmail"
+	"mailassist/internal/infrastructure/graph"
+	"mailassist/internal/infrastructure/imap"
 	"mailassist/internal/infrastructure/llm"
+	"mailassist/internal/infrastructure/oidc"
 	"mailassist/internal/infrastructure/persistence/sqlite"
 	"mailassist/internal/infrastructure/pubsub"
+	"mailassist/internal/infrastructure/ratelimit"
+	"mailassist/internal/infrastructure/rules"
+	"mailassist/internal/interfaces/poll"
 	pubsubHandler "mailassist/internal/interfaces/pubsub"
+	"mailassist/internal/interfaces/watch"
 	"mailassist/internal/interfaces/worker"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run starts the service and blocks until it is stopped. It returns the exit
+// code once every deferred cleanup has run.
+func run() int {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// Background components report unrecoverable errors here
+	fatal := make(chan error, 1)
+
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		log.Printf("Failed to load config: %v", err)
+		return 1
 	}
 
-	repo, err := sqlite.NewEmailRepository(cfg.DatabasePath)
+	db, err := sqlite.Open(cfg.DatabasePath)
 	if err != nil {
-		log.Fatalf("Failed to create repository: %v", err)
+		log.Printf("Failed to open database: %v", err)
+		return 1
 	}
 	defer func() {
-		if err := repo.Close(); err != nil {
-			log.Printf("Failed to close repository: %v", err)
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
 		}
 	}()
 
-	llmClient, err := llm.NewClient()
+	repo := sqlite.NewEmailRepository(db)
+	syncState := sqlite.NewSyncStateRepository(db)
+	jobQueue := sqlite.NewJobQueueRepository(db)
+	failedJobs := sqlite.NewFailedJobRepository(db)
+	ruleHits := sqlite.NewRuleHitRepository(db)
+	corrections := sqlite.NewCorrectionRepository(db)
+
+	ruleSet, err := rules.LoadFile(cfg.RulesPath)
 	if err != nil {
-		log.Fatalf("Failed to create LLM client: %v", err)
+		log.Printf("Failed to load rules: %v", err)
+		return 1
 	}
 
-	gmailService, err := gmail.NewService(ctx)
+	// Shared limiter so the LLM budget holds regardless of the number of
+	// workers and accounts
+	llmClient, err := llm.New(llm.Config{
+		Provider: cfg.LLMProvider,
+		APIKey:   cfg.LLMAPIKey,
+		BaseURL:  cfg.LLMBaseURL,
+		Model:    cfg.ModelName,
+		Limiter:  ratelimit.NewLimiter(float64(cfg.LLMRequestsPerMinute)/60, cfg.LLMTokensPerMinute),
+	})
 	if err != nil {
-		log.Fatalf("Failed to create Gmail service: %v", err)
+		log.Printf("Failed to create LLM client: %v", err)
+		return 1
 	}
 
-	gmailClient := gmail.NewClient(gmailService)
-
-	if err := gmailClient.InitLabels(); err != nil {
-		log.Fatalf("Failed to initialize labels: %v", err)
+	// Each account gets its own mailbox client, label cache, checkpoint and,
+	// on Gmail, quota limiter and watch
+	type mailbox struct {
+		account     string
+		service     mailService
+		attachments email.AttachmentService
+		labels      email.LabelHistory
+		state       *sqlite.SyncStateRepository
+		imap        *imap.Client
 	}
+	var mailboxes []mailbox
+	useCases := make(map[string]*email.ClassifyEmailUseCase, len(cfg.Accounts))
 
-	// Enable Gmail watch for push notifications
-	if err := gmailClient.EnableWatch(ctx, cfg.TopicName); err != nil {
-		log.Printf("Warning: Failed to enable watch: %v", err)
+	// Graph authenticates as the app, one client serves every mailbox
+	var graphHTTP *http.Client
+	if cfg.MailBackend == config.MailBackendGraph {
+		graphHTTP = graph.NewHTTPClient(ctx, cfg.GraphTenantID, cfg.GraphClientID, cfg.GraphClientSecret)
 	}
 
-	classifyUC := email.NewClassifyEmailUseCase(repo, llmClient, gmailClient)
+	for _, account := range cfg.Accounts {
+		state := syncState.ForAccount(account.ID)
+		m := mailbox{account: account.ID, state: state}
+
+		switch cfg.MailBackend {
+		case config.MailBackendIMAP:
+			m.imap = imap.NewClient(imap.Config{
+				Addr:          cfg.IMAPAddr,
+				Username:      cfg.IMAPUsername,
+				Password:      cfg.IMAPPassword,
+				TLS:           cfg.IMAPTLS,
+				Mailbox:       cfg.IMAPMailbox,
+				DraftsMailbox: cfg.IMAPDraftsMailbox,
+				LabelMode:     cfg.IMAPLabelMode,
+			})
+			defer m.imap.Close()
+
+			if err := m.imap.InitLabels(); err != nil {
+				log.Printf("Failed to initialize labels for %s: %v", account.ID, err)
+				return 1
+			}
+			m.service = m.imap
+
+		case config.MailBackendGraph:
+			graphClient := graph.NewClient(graphHTTP, cfg.GraphBaseURL, account.ID, state)
+			if err := graphClient.InitLabels(ctx); err != nil {
+				log.Printf("Failed to initialize categories for %s: %v", account.ID, err)
+				return 1
+			}
+			m.service = graphClient
+
+		default:
+			oauth := gmail.OAuthConfig{
+				ServiceAccountKeyPath: cfg.ServiceAccountKeyPath,
+				Subject:               account.ID,
+				CredentialsPath:       cfg.CredentialsPath,
+			}
+			if cfg.ServiceAccountKeyPath == "" {
+				oauth.Tokens, err = gmail.NewFileTokenStore(account.TokenPath, cfg.TokenKey)
+				if err != nil {
+					log.Printf("Failed to open token store for %s: %v", account.ID, err)
+					return 1
+				}
+				if account.ID != "me" {
+					oauth.LoginHint = account.ID
+				}
+			}
+
+			gmailService, err := gmail.NewService(ctx, oauth)
+			if err != nil {
+				log.Printf("Failed to create Gmail service for %s: %v", account.ID, err)
+				return 1
+			}
+
+			gmailClient := gmail.NewClient(gmailService)
 
-	pool := worker.NewPool(cfg.NumWorkers, classifyUC)
+			if account.ID != "me" {
+				address, err := gmailClient.EmailAddress(ctx)
+				if err != nil {
+					log.Printf("Failed to read Gmail profile for %s: %v", account.ID, err)
+					return 1
+				}
+				if !strings.EqualFold(address, account.ID) {
+					log.Printf("Token %s belongs to %s, not %s", account.TokenPath, address, account.ID)
+					return 1
+				}
+			}
+
+			if err := gmailClient.InitLabels(); err != nil {
+				log.Printf("Failed to initialize labels for %s: %v", account.ID, err)
+				return 1
+			}
+
+			// Polling needs no watch; Pub/Sub modes keep one registered and renewed
+			if cfg.PubSubMode != config.PubSubModePoll {
+				renewer := watch.NewRenewer(gmailClient, state, cfg.TopicName, cfg.WatchRenewBefore)
+				if _, err := renewer.Renew(ctx); err != nil {
+					log.Printf("Failed to enable watch for %s: %v", account.ID, err)
+					return 1
+				}
+				if cfg.StopWatchOnShutdown {
+					defer func() {
+						stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+						defer cancel()
+						if err := renewer.Stop(stopCtx); err != nil {
+							log.Printf("Failed to stop watch for %s: %v", account.ID, err)
+						}
+					}()
+				}
+				go func() {
+					if err := renewer.Run(ctx); err != nil {
+						fatal <- fmt.Errorf("%s: %w", account.ID, err)
+					}
+				}()
+			}
+
+			// Gmail quota is per user, so every account has its own limiter
+			limited := ratelimit.NewGmail(gmailClient, ratelimit.NewLimiter(cfg.GmailQuotaUnitsPerSecond, 0))
+			m.service = limited
+			m.attachments = limited
+			m.labels = limited
+		}
+
+		var fewShot *email.FewShotSelector
+		if cfg.FewShotExamples > 0 && cfg.FewShotTokenBudget > 0 {
+			fewShot = email.NewFewShotSelector(repo.ForAccount(account.ID), cfg.FewShotExamples, cfg.FewShotTokenBudget)
+		}
+
+		useCases[account.ID] = email.NewClassifyEmailUseCase(
+			repo.ForAccount(account.ID),
+			llmClient,
+			m.service,
+			ruleSet,
+			ruleHits.ForAccount(account.ID),
+			corrections.ForAccount(account.ID),
+			fewShot,
+			m.attachments,
+		)
+		mailboxes = append(mailboxes, m)
+	}
+
+	retry := worker.RetryPolicy{
+		MaxAttempts: cfg.JobMaxAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+	}
+	pool := worker.NewPool(cfg.NumWorkers, jobQueue, useCases, failedJobs, retry, cfg.JobVisibilityTimeout)
 	pool.Start(ctx)
 	defer pool.Shutdown()
 
-	// Pub/Sub subscriber
-	subscriber, err := pubsub.NewSubscriber(ctx, cfg.GoogleCloudProject, cfg.SubscriptionID)
-	if err != nil {
-		log.Fatalf("Failed to create subscriber: %v", err)
+	// Pub/Sub notification dedup, shared by pull and push mode
+	var dedupStore pubsub.DedupStore
+	if cfg.DedupPersist {
+		dedupStore = sqlite.NewNotificationRepository(db)
 	}
-	defer func() {
-		if err := subscriber.Close(); err != nil {
-			log.Printf("Failed to close subscriber: %v", err)
+	dedup := pubsub.NewDedup(cfg.DedupTTL, cfg.DedupMaxEntries, dedupStore)
+
+	syncers := make(map[string]pubsubHandler.MailboxSyncer, len(mailboxes))
+	for _, m := range mailboxes {
+		recordCorrection := email.NewRecordCorrectionUseCase(
+			repo.ForAccount(m.account),
+			corrections.ForAccount(m.account),
+		)
+		syncUC := email.NewSyncMailboxUseCase(
+			m.service,
+			m.state,
+			pool.ForAccount(m.account),
+			m.labels,
+			recordCorrection,
+			cfg.InitialEmailsToFetch,
+			cfg.ResyncMaxMessages,
+		)
+		syncers[m.account] = syncUC
+
+		// Catch up on everything since the last checkpoint
+		log.Printf("Catching up %s from last history checkpoint...", m.account)
+		if err := syncUC.Execute(ctx); err != nil {
+			log.Printf("Warning: Failed to catch up %s: %v", m.account, err)
 		}
-	}()
+	}
 
-	// Pub/Sub handler
-	handler := pubsubHandler.NewHandler(pool, gmailClient)
+	// Pub/Sub handler, routes notifications by mailbox address
+	handler := pubsubHandler.NewHandler(ctx, syncers)
 
-	// Process initial emails
-	log.Printf("Processing initial batch of %d emails...", cfg.InitialEmailsToFetch)
-	if err := processInitialEmails(ctx, gmailClient, pool, cfg.InitialEmailsToFetch); err != nil {
-		log.Printf("Warning: Failed to process initial emails: %v", err)
+	notify := func(n *pubsub.Notification) error {
+		return handler.HandleNotification(ctx, n.EmailAddress, n.HistoryID)
 	}
 
-	// Start Pub/Sub listener in background
-	go func() {
-		log.Println("Starting Pub/Sub listener...")
-		if err := subscriber.Listen(ctx, func(historyID uint64) {
-			handler.HandleNotification(ctx, historyID)
-		}); err != nil && ctx.Err() == nil {
-			log.Printf("Pub/Sub listener error: %v", err)
+	switch {
+	case cfg.MailBackend == config.MailBackendIMAP:
+		for _, m := range mailboxes {
+			go func() {
+				log.Printf("Watching %s with IMAP IDLE", m.account)
+				err := m.imap.Listen(ctx, func() error {
+					return handler.HandleNotification(ctx, m.account, 0)
+				})
+				if err != nil {
+					fatal <- fmt.Errorf("%s: IMAP IDLE: %w", m.account, err)
+				}
+			}()
 		}
-	}()
+
+	case cfg.MailBackend == config.MailBackendGraph || cfg.PubSubMode == config.PubSubModePoll:
+		log.Printf("Polling mailbox history every %s", cfg.PollInterval)
+		for _, m := range mailboxes {
+			go poll.NewPoller(handler, m.account, cfg.PollInterval).Run(ctx)
+		}
+
+	case cfg.PubSubMode == config.PubSubModePush:
+		server := newPushServer(cfg, dedup, notify)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Failed to shut down push server: %v", err)
+			}
+		}()
+
+		go func() {
+			log.Printf("Listening for Pub/Sub pushes on %s%s", cfg.PushListenAddr, cfg.PushPath)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal <- fmt.Errorf("push server: %w", err)
+			}
+		}()
+
+	default:
+		subscriber, err := pubsub.NewSubscriber(ctx, cfg.GoogleCloudProject, cfg.SubscriptionID, dedup)
+		if err != nil {
+			log.Printf("Failed to create subscriber: %v", err)
+			return 1
+		}
+		defer func() {
+			if err := subscriber.Close(); err != nil {
+				log.Printf("Failed to close subscriber: %v", err)
+			}
+		}()
+
+		// Start Pub/Sub listener in background
+		go func() {
+			log.Println("Starting Pub/Sub listener...")
+			if err := subscriber.Listen(ctx, notify); err != nil && ctx.Err() == nil {
+				log.Printf("Pub/Sub listener error: %v", err)
+			}
+		}()
+	}
 
 	log.Println("MailAssist is running. Press Ctrl+C to stop.")
 
-	<-ctx.Done()
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+	case err := <-fatal:
+		log.Printf("FATAL: %v", err)
+		exitCode = 1
+		stop()
+	}
 	log.Println("Shutting down gracefully...")
+	return exitCode
 }
 
-func processInitialEmails(ctx context.Context, gmailClient *gmail.Client, pool *worker.Pool, maxResults int64) error {
-	messageIDs, err := gmailClient.ListMessagesFromInbox(ctx, maxResults)
-	if err != nil {
-		return err
+// mailService is what the use cases need from a mailbox backend
+type mailService interface {
+	email.GmailService
+	email.MailboxHistory
+}
+
+func newPushServer(cfg *config.Config, dedup *pubsub.Dedup, notify func(n *pubsub.Notification) error) *http.Server {
+	var verifier pubsub.TokenVerifier
+	if cfg.PushVerifyToken {
+		verifier = oidc.NewVerifier(oidc.NewJWKSKeySource(oidc.GoogleCertsURL), cfg.PushAudience, cfg.PushServiceAccount)
+	} else {
+		log.Println("Warning: Pub/Sub push token verification is disabled")
 	}
 
-	log.Printf("Found %d messages to process", len(messageIDs))
+	mux := http.NewServeMux()
+	mux.Handle(cfg.PushPath, pubsub.NewPushHandler(verifier, dedup, notify))
 
-	for _, msgID := range messageIDs {
-		pool.Submit(worker.EmailJob{GmailID: msgID})
+	return &http.Server{
+		Addr:              cfg.PushListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
-
-	return nil
 }
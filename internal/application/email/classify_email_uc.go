@@ -4,23 +4,45 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
+
+	"mailassist/internal/domain/email"
+	"mailassist/internal/domain/rule"
 )
 
+// maxAttachmentExcerpt bounds the attachment text sent to the LLM
+const maxAttachmentExcerpt = 4000
+
 type ClassifyEmailUseCase struct {
 	repo         EmailRepository
 	llm          LLMClassifier
 	gmailService GmailService
+	rules        RuleMatcher
+	ruleHits     RuleHitRepository
+	corrections  CorrectionRepository
+	examples     *FewShotSelector
+	attachments  AttachmentService
 }
 
 func NewClassifyEmailUseCase(
 	repo EmailRepository,
 	llm LLMClassifier,
 	gmailService GmailService,
+	rules RuleMatcher,
+	ruleHits RuleHitRepository,
+	corrections CorrectionRepository,
+	examples *FewShotSelector, // nil disables few-shot examples
+	attachments AttachmentService, // nil when the mailbox cannot read attachments
 ) *ClassifyEmailUseCase {
 	return &ClassifyEmailUseCase{
 		repo:         repo,
 		llm:          llm,
 		gmailService: gmailService,
+		rules:        rules,
+		ruleHits:     ruleHits,
+		corrections:  corrections,
+		examples:     examples,
+		attachments:  attachments,
 	}
 }
 
@@ -30,8 +52,7 @@ func (uc *ClassifyEmailUseCase) Execute(ctx context.Context, gmailID string) err
 		return fmt.Errorf("check processed: %w", err)
 	}
 	if processed {
-		log.Printf("Email %s already processed, skipping", gmailID)
-		return nil
+		return uc.resume(ctx, gmailID)
 	}
 
 	emailEntity, err := uc.gmailService.FetchEmail(ctx, gmailID)
@@ -39,43 +60,244 @@ func (uc *ClassifyEmailUseCase) Execute(ctx context.Context, gmailID string) err
 		return fmt.Errorf("fetch email: %w", err)
 	}
 
-	// Skip if empty body
-	if emailEntity.Body == "" {
-		log.Printf("Empty body for %s, skipping", gmailID)
-		return nil
+	hit := uc.rules.Match(emailEntity)
+	if hit != nil {
+		log.Printf("Rule %q matched %s", hit.Name, gmailID)
 	}
 
-	// Classify using LLM
-	classification, err := uc.llm.Classify(ctx, emailEntity.Subject, emailEntity.Body)
+	classification, err := uc.classify(ctx, emailEntity, hit)
 	if err != nil {
-		return fmt.Errorf("classify email: %w", err)
+		return err
+	}
+	if classification == nil {
+		log.Printf("Empty body for %s, skipping", gmailID)
+		return nil
 	}
 
 	// Update domain entity
 	emailEntity.Classify(classification.Category, classification.Label)
 
-	// Apply label in Gmail
-	if err := uc.gmailService.ApplyLabel(ctx, gmailID, emailEntity.Label); err != nil {
-		log.Printf("Failed to apply label for %s: %v", gmailID, err)
+	// Saved before the mailbox is changed, together with what is still to
+	// change there: a retry then finishes the label and draft without
+	// calling the LLM again or creating a second draft
+	emailEntity.LabelPending = true
+	if emailEntity.NeedsReply() {
+		emailEntity.PendingReply = classification.Reply
+	}
+	record := email.NewClassificationRecord(gmailID, classification)
+	if err := uc.repo.SaveClassified(ctx, emailEntity, record); err != nil {
+		return fmt.Errorf("save email: %w", err)
 	}
 
-	// Create draft reply if needed
-	if emailEntity.NeedsReply() && classification.Reply != "" {
-		if err := uc.gmailService.CreateDraft(
-			ctx,
-			emailEntity.From,
-			"Re: "+emailEntity.Subject,
-			classification.Reply,
-		); err != nil {
-			log.Printf("Failed to create draft for %s: %v", gmailID, err)
+	// Recorded once the outcome is stored, so retries and skipped emails
+	// leave no hits behind. The email is already classified, a retry would
+	// not record it either.
+	if hit != nil {
+		if err := uc.ruleHits.SaveRuleHit(ctx, gmailID, hit); err != nil {
+			log.Printf("Failed to record rule hit for %s: %v", gmailID, err)
 		}
 	}
 
-	if err := uc.repo.Save(ctx, emailEntity); err != nil {
-		return fmt.Errorf("save email: %w", err)
+	if err := uc.applyPending(ctx, emailEntity); err != nil {
+		return err
 	}
 
 	log.Printf("OK: %s – category=%s label=%s", gmailID, emailEntity.Category, emailEntity.Label)
 
 	return nil
 }
+
+// resume finishes the mailbox changes of an email classified by an earlier
+// attempt
+func (uc *ClassifyEmailUseCase) resume(ctx context.Context, gmailID string) error {
+	e, err := uc.repo.GetById(ctx, gmailID)
+	if err != nil {
+		return fmt.Errorf("load email: %w", err)
+	}
+	if !e.Pending() {
+		log.Printf("Email %s already processed, skipping", gmailID)
+		return nil
+	}
+
+	log.Printf("Email %s already classified, finishing its label and draft", gmailID)
+
+	// Threading headers are not stored, the reply needs them from the mailbox
+	if e.PendingReply != "" {
+		fetched, err := uc.gmailService.FetchEmail(ctx, gmailID)
+		if err != nil {
+			return fmt.Errorf("fetch email: %w", err)
+		}
+		fetched.Classify(e.Category, e.Label)
+		fetched.LabelPending = e.LabelPending
+		fetched.PendingReply = e.PendingReply
+		e = fetched
+	}
+
+	return uc.applyPending(ctx, e)
+}
+
+// applyPending applies the label and creates the reply draft. Transient
+// failures and interruptions are returned so the job is retried; the stored
+// email keeps what is still pending. Permanent failures are logged and given
+// up on.
+func (uc *ClassifyEmailUseCase) applyPending(ctx context.Context, e *email.Email) error {
+	if e.LabelPending {
+		if err := uc.gmailService.ApplyLabel(ctx, e.GmailID, e.Label); err != nil {
+			if IsTransient(err) || ctx.Err() != nil {
+				return fmt.Errorf("apply label: %w", err)
+			}
+			log.Printf("Failed to apply label for %s: %v", e.GmailID, err)
+		}
+		if err := uc.repo.FinishLabel(ctx, e.GmailID); err != nil {
+			return err
+		}
+		e.LabelPending = false
+	}
+
+	if e.PendingReply != "" {
+		draft := e.PendingReply
+		if err := uc.gmailService.CreateDraft(ctx, e, draft); err != nil {
+			if IsTransient(err) || ctx.Err() != nil {
+				return fmt.Errorf("create draft: %w", err)
+			}
+			log.Printf("Failed to create draft for %s: %v", e.GmailID, err)
+			draft = ""
+		}
+		if err := uc.repo.FinishDraft(ctx, e.GmailID, draft); err != nil {
+			return err
+		}
+		e.PendingReply = ""
+	}
+
+	return nil
+}
+
+// classify applies the matched rule first, then the label the user last
+// corrected the sender to, and only calls the LLM when neither decided or a
+// reply draft is still needed. It returns nil when nothing decided and the
+// email has neither a body nor attachment text.
+func (uc *ClassifyEmailUseCase) classify(ctx context.Context, e *email.Email, hit *rule.Rule) (*email.Classification, error) {
+	if hit != nil && hit.SkipLLM {
+		return ruleClassification(hit), nil
+	}
+
+	// Explicit rules win over what was learned from corrections
+	var override email.Label
+	if hit == nil {
+		var err error
+		if override, err = uc.senderOverride(ctx, e); err != nil {
+			return nil, err
+		}
+	}
+	if override != "" && override != email.LabelActionNeeded {
+		return senderClassification(override), nil
+	}
+
+	if err := uc.readAttachments(ctx, e); err != nil {
+		return nil, err
+	}
+
+	body := e.Body
+	if excerpt := e.AttachmentsText(maxAttachmentExcerpt); excerpt != "" {
+		body = strings.TrimSpace(body + "\n\n" + excerpt)
+	}
+	if body == "" {
+		// The LLM has nothing to read, a label already decided still applies
+		switch {
+		case hit != nil:
+			return ruleClassification(hit), nil
+		case override != "":
+			return senderClassification(override), nil
+		}
+		return nil, nil
+	}
+
+	var examples []email.Example
+	if uc.examples != nil {
+		var err error
+		if examples, err = uc.examples.Select(ctx, e); err != nil {
+			return nil, err
+		}
+	}
+
+	classification, err := uc.llm.Classify(ctx, e.Subject, body, examples)
+	if err != nil {
+		return nil, fmt.Errorf("classify email: %w", err)
+	}
+
+	switch {
+	case hit != nil:
+		classification.Category = hit.Category
+		classification.Label = hit.Label
+		classification.Source = email.SourceRule
+	case override != "":
+		classification.Category = email.Category(override)
+		classification.Label = override
+		classification.Source = email.SourceSender
+	}
+	if classification.Category != email.CategoryActionNeeded {
+		classification.Reply = ""
+	}
+
+	return classification, nil
+}
+
+func ruleClassification(hit *rule.Rule) *email.Classification {
+	classification := email.NewClassification(hit.Category, hit.Label, "", "")
+	classification.Source = email.SourceRule
+	return classification
+}
+
+func senderClassification(label email.Label) *email.Classification {
+	classification := email.NewClassification(email.Category(label), label, "", "")
+	classification.Source = email.SourceSender
+	return classification
+}
+
+// senderOverride returns the label the user last corrected an email from
+// this sender to, if any
+func (uc *ClassifyEmailUseCase) senderOverride(ctx context.Context, e *email.Email) (email.Label, error) {
+	sender := e.SenderAddress()
+	if sender == "" {
+		return "", nil
+	}
+
+	label, ok, err := uc.corrections.SenderLabel(ctx, sender)
+	if err != nil {
+		return "", fmt.Errorf("load sender correction: %w", err)
+	}
+	if !ok {
+		return "", nil
+	}
+
+	log.Printf("Sender %s was corrected to %s, applying it to %s", sender, label, e.GmailID)
+	return label, nil
+}
+
+// readAttachments extracts the text of attachments that were not read with
+// the email. An attachment that cannot be read is left out; only transient
+// failures fail the job so it is retried.
+func (uc *ClassifyEmailUseCase) readAttachments(ctx context.Context, e *email.Email) error {
+	if uc.attachments == nil {
+		return nil
+	}
+
+	for i := range e.Attachments {
+		a := &e.Attachments[i]
+		if a.Text != "" {
+			continue
+		}
+
+		text, err := uc.attachments.AttachmentText(ctx, e.GmailID, a)
+		if IsTransient(err) {
+			return fmt.Errorf("read attachment %s: %w", a.Filename, err)
+		}
+		if err != nil {
+			log.Printf("Skipping attachment %s of %s: %v", a.Filename, e.GmailID, err)
+			continue
+		}
+		a.Text = text
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package email
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrHistoryExpired is returned when the mail provider no longer has history
+// for the requested checkpoint and a full resync is required
+var ErrHistoryExpired = errors.New("history id expired")
+
+// TransientError marks a failure that may succeed when retried, such as a
+// rate limit, a 5xx response or a network timeout
+type TransientError struct {
+	Err error
+
+	// RetryAfter is the delay requested by the upstream API, if any
+	RetryAfter time.Duration
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// Transient wraps err as retryable
+func Transient(err error, retryAfter time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err, RetryAfter: retryAfter}
+}
+
+// IsTransient reports whether err, or any error it wraps, is retryable
+func IsTransient(err error) bool {
+	var t *TransientError
+	return errors.As(err, &t)
+}
+
+// RetryAfter returns the delay requested by the upstream API, or 0
+func RetryAfter(err error) time.Duration {
+	var t *TransientError
+	if errors.As(err, &t) {
+		return t.RetryAfter
+	}
+	return 0
+}
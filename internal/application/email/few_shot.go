@@ -0,0 +1,147 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"mailassist/internal/domain/email"
+)
+
+const (
+	// fewShotCandidates is how many recent confirmed emails are ranked
+	fewShotCandidates = 200
+	// maxExampleBody cuts each example's body, in bytes
+	maxExampleBody = 600
+)
+
+// FewShotSelector picks confirmed emails similar to the one being classified
+// as few-shot examples, keeping their rendered prompt section within a token
+// budget
+type FewShotSelector struct {
+	repo        ExampleRepository
+	maxExamples int
+	tokenBudget int
+}
+
+func NewFewShotSelector(repo ExampleRepository, maxExamples, tokenBudget int) *FewShotSelector {
+	return &FewShotSelector{
+		repo:        repo,
+		maxExamples: maxExamples,
+		tokenBudget: tokenBudget,
+	}
+}
+
+// Select returns the most similar examples first. Emails from the same
+// sender rank highest, then the same domain, then shared words.
+func (s *FewShotSelector) Select(ctx context.Context, e *email.Email) ([]email.Example, error) {
+	candidates, err := s.repo.ConfirmedEmails(ctx, e.GmailID, fewShotCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("load confirmed emails: %w", err)
+	}
+
+	type scored struct {
+		email *email.Email
+		score float64
+	}
+	words := wordSet(e)
+	var ranked []scored
+	for _, c := range candidates {
+		score := wordOverlap(words, wordSet(c))
+		switch {
+		case e.SenderAddress() != "" && c.SenderAddress() == e.SenderAddress():
+			score += 2
+		case e.SenderDomain() != "" && c.SenderDomain() == e.SenderDomain():
+			score += 1
+		}
+		if score > 0 {
+			ranked = append(ranked, scored{email: c, score: score})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	var examples []email.Example
+	for _, r := range ranked {
+		if len(examples) == s.maxExamples {
+			break
+		}
+		next := append(examples, email.Example{
+			From:     r.email.From,
+			Subject:  r.email.Subject,
+			Body:     excerpt(r.email.Body, maxExampleBody),
+			Category: r.email.Category,
+			Label:    r.email.Label,
+		})
+		if EstimateTokens(FormatExamples(next)) > s.tokenBudget {
+			break
+		}
+		examples = next
+	}
+
+	return examples, nil
+}
+
+// FormatExamples renders the prompt section that shows the LLM how the user
+// labeled similar emails; the selector's budget is measured on this text
+func FormatExamples(examples []email.Example) string {
+	if len(examples) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\nThe user labeled these similar emails as follows; follow their judgement where it applies:\n")
+	for i, ex := range examples {
+		fmt.Fprintf(&b, "\nExample %d:\nFrom: %s\nSubject: %s\nBody:\n%s\nAnswer: {\"category\":%q,\"label\":%q}\n",
+			i+1, ex.From, ex.Subject, ex.Body, ex.Category, ex.Label)
+	}
+	return b.String()
+}
+
+// wordSet returns the distinct lower-cased words of subject and body start
+func wordSet(e *email.Email) map[string]bool {
+	text := e.Subject + " " + excerpt(e.Body, maxExampleBody)
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		// Short words are mostly stop words
+		if len([]rune(w)) > 3 {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+// wordOverlap is the Jaccard index of two word sets
+func wordOverlap(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// excerpt cuts s to at most n bytes without splitting a rune
+func excerpt(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// EstimateTokens uses the common ~4 bytes per token approximation
+func EstimateTokens(parts ...string) int {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	return n/4 + 1
+}
@@ -2,21 +2,98 @@ package email
 
 import (
 	"context"
+
 	"mailassist/internal/domain/email"
+	"mailassist/internal/domain/rule"
 )
 
+// LLMClassifier classifies an email, guided by examples of how the user
+// labels similar ones
 type LLMClassifier interface {
-	Classify(ctx context.Context, subject, body string) (*email.Classification, error)
+	Classify(ctx context.Context, subject, body string, examples []email.Example) (*email.Classification, error)
 }
 
 type EmailRepository interface {
 	GetById(ctx context.Context, gmailID string) (*email.Email, error)
-	Save(ctx context.Context, e *email.Email) error
+	// SaveClassified saves the email and appends rec to its classification
+	// history atomically
+	SaveClassified(ctx context.Context, e *email.Email, rec *email.ClassificationRecord) error
+	// FinishLabel and FinishDraft clear the email's pending mailbox changes;
+	// FinishDraft keeps the draft created, an empty one was given up on
+	FinishLabel(ctx context.Context, gmailID string) error
+	FinishDraft(ctx context.Context, gmailID, draft string) error
 	EmailAlreadyProcessed(ctx context.Context, gmailID string) (bool, error)
 }
 
 type GmailService interface {
 	FetchEmail(ctx context.Context, messageID string) (*email.Email, error)
 	ApplyLabel(ctx context.Context, messageID string, label email.Label) error
-	CreateDraft(ctx context.Context, recipient, subject, body string) error
+	CreateDraft(ctx context.Context, original *email.Email, body string) error
+}
+
+// ExampleRepository lists emails whose label the user confirmed, by
+// correcting it or through a rule, newest first
+type ExampleRepository interface {
+	ConfirmedEmails(ctx context.Context, excludeGmailID string, limit int) ([]*email.Email, error)
+}
+
+// AttachmentService reads attachments on demand, so they are only
+// downloaded when the LLM needs them
+type AttachmentService interface {
+	AttachmentText(ctx context.Context, messageID string, a *email.Attachment) (string, error)
+}
+
+type RuleMatcher interface {
+	Match(e *email.Email) *rule.Rule
+}
+
+type RuleHitRepository interface {
+	SaveRuleHit(ctx context.Context, gmailID string, hit *rule.Rule) error
+}
+
+// CorrectionRepository stores the user's label corrections
+type CorrectionRepository interface {
+	SaveCorrection(ctx context.Context, c *email.Correction) error
+	// SenderLabel returns the label of the latest correction that added one
+	// of our labels to an email from sender
+	SenderLabel(ctx context.Context, sender string) (email.Label, bool, error)
+}
+
+// LabelChange is one of the assistant's labels being added to or removed
+// from a message
+type LabelChange struct {
+	GmailID string
+	Label   email.Label
+	Added   bool
+}
+
+// LabelHistory streams changes to the assistant's labels recorded after
+// historyID and up to untilID, so label changes cover the same range as the
+// message history pass
+type LabelHistory interface {
+	StreamLabelChangesSince(ctx context.Context, historyID, untilID uint64, fn func(LabelChange) error) error
+}
+
+type MailboxHistory interface {
+	CurrentHistoryID(ctx context.Context) (uint64, error)
+	StreamNewMessagesSince(ctx context.Context, historyID uint64, fn func(gmailID string) error) (uint64, error)
+	StreamInboxMessages(ctx context.Context, maxResults int64, fn func(gmailID string) error) error
+}
+
+type CheckpointRepository interface {
+	LoadHistoryID(ctx context.Context) (uint64, error)
+	SaveHistoryID(ctx context.Context, historyID uint64) error
+}
+
+type JobQueue interface {
+	Enqueue(ctx context.Context, gmailID string) error
+}
+
+// Job is a unit of work leased from the durable job queue
+type Job struct {
+	ID         int64
+	Account    string
+	GmailID    string
+	Attempt    int
+	LeaseToken string
 }
@@ -0,0 +1,70 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"mailassist/internal/domain/email"
+)
+
+// RecordCorrectionUseCase turns the user relabeling an email in the mailbox
+// into a manual classification
+type RecordCorrectionUseCase struct {
+	repo        EmailRepository
+	corrections CorrectionRepository
+}
+
+func NewRecordCorrectionUseCase(
+	repo EmailRepository,
+	corrections CorrectionRepository,
+) *RecordCorrectionUseCase {
+	return &RecordCorrectionUseCase{
+		repo:        repo,
+		corrections: corrections,
+	}
+}
+
+// Execute records a label change made by the user. Changes made by the
+// assistant itself are ignored: its label is either not stored yet or
+// matches the stored one.
+func (uc *RecordCorrectionUseCase) Execute(ctx context.Context, change LabelChange) error {
+	processed, err := uc.repo.EmailAlreadyProcessed(ctx, change.GmailID)
+	if err != nil {
+		return fmt.Errorf("check processed: %w", err)
+	}
+	if !processed {
+		return nil
+	}
+
+	e, err := uc.repo.GetById(ctx, change.GmailID)
+	if err != nil {
+		return fmt.Errorf("load email: %w", err)
+	}
+
+	// Only adding a different label or removing the current one is the user
+	// disagreeing with us
+	if change.Added == (change.Label == e.Label) {
+		return nil
+	}
+
+	if err := uc.corrections.SaveCorrection(ctx, email.NewCorrection(e, change.Label, change.Added)); err != nil {
+		return fmt.Errorf("save correction: %w", err)
+	}
+
+	if !change.Added {
+		log.Printf("User removed label %s from %s", change.Label, change.GmailID)
+		return nil
+	}
+
+	log.Printf("User relabeled %s from %s to %s", change.GmailID, e.Label, change.Label)
+
+	classification := email.NewClassification(email.Category(change.Label), change.Label, "", "")
+	classification.Source = email.SourceManual
+	e.Classify(classification.Category, classification.Label)
+	if err := uc.repo.SaveClassified(ctx, e, email.NewClassificationRecord(e.GmailID, classification)); err != nil {
+		return fmt.Errorf("save email: %w", err)
+	}
+
+	return nil
+}
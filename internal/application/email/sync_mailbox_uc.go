@@ -0,0 +1,140 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// SyncMailboxUseCase enqueues every message added since the stored historyId
+// checkpoint, so nothing is lost while the service is down
+type SyncMailboxUseCase struct {
+	history     MailboxHistory
+	checkpoints CheckpointRepository
+	queue       JobQueue
+
+	// labels and corrections pick up the user relabeling emails; nil when
+	// the mailbox has no label history
+	labels      LabelHistory
+	corrections *RecordCorrectionUseCase
+
+	// initialLimit bounds the first run, resyncLimit the recovery after an
+	// expired checkpoint
+	initialLimit int64
+	resyncLimit  int64
+
+	// syncs must not overlap, otherwise checkpoints could move backwards
+	mu sync.Mutex
+}
+
+func NewSyncMailboxUseCase(
+	history MailboxHistory,
+	checkpoints CheckpointRepository,
+	queue JobQueue,
+	labels LabelHistory,
+	corrections *RecordCorrectionUseCase,
+	initialLimit, resyncLimit int64,
+) *SyncMailboxUseCase {
+	return &SyncMailboxUseCase{
+		history:      history,
+		checkpoints:  checkpoints,
+		queue:        queue,
+		labels:       labels,
+		corrections:  corrections,
+		initialLimit: initialLimit,
+		resyncLimit:  resyncLimit,
+	}
+}
+
+func (uc *SyncMailboxUseCase) Execute(ctx context.Context) error {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	startID, err := uc.checkpoints.LoadHistoryID(ctx)
+	if err != nil {
+		return fmt.Errorf("load checkpoint: %w", err)
+	}
+
+	if startID == 0 {
+		log.Println("No history checkpoint found, running initial sync")
+		return uc.fullResync(ctx, uc.initialLimit)
+	}
+
+	// Messages are enqueued page by page so workers start before the last
+	// page is fetched
+	var count int
+	latestID, err := uc.history.StreamNewMessagesSince(ctx, startID, func(msgID string) error {
+		count++
+		return uc.enqueue(ctx, msgID)
+	})
+	if errors.Is(err, ErrHistoryExpired) {
+		log.Printf("History checkpoint %d expired, running full resync", startID)
+		return uc.fullResync(ctx, uc.resyncLimit)
+	}
+	if err != nil {
+		return fmt.Errorf("fetch history: %w", err)
+	}
+
+	if count > 0 {
+		log.Printf("Found %d new message(s) since historyID: %d", count, startID)
+	}
+
+	if uc.labels != nil && uc.corrections != nil && latestID > startID {
+		err := uc.labels.StreamLabelChangesSince(ctx, startID, latestID, func(change LabelChange) error {
+			return uc.corrections.Execute(ctx, change)
+		})
+		// The new messages are already enqueued, so a resync would not help;
+		// the corrections in the expired range are lost
+		if errors.Is(err, ErrHistoryExpired) {
+			log.Printf("Label history since %d expired, skipping label changes up to %d", startID, latestID)
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("fetch label history: %w", err)
+		}
+	}
+
+	if latestID > startID {
+		if err := uc.checkpoints.SaveHistoryID(ctx, latestID); err != nil {
+			return fmt.Errorf("save checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// fullResync lists the inbox and restarts history from the current historyId.
+// The historyId is read first so that messages arriving during the listing
+// are picked up by the next history sync.
+func (uc *SyncMailboxUseCase) fullResync(ctx context.Context, limit int64) error {
+	currentID, err := uc.history.CurrentHistoryID(ctx)
+	if err != nil {
+		return fmt.Errorf("current history id: %w", err)
+	}
+
+	var count int
+	err = uc.history.StreamInboxMessages(ctx, limit, func(msgID string) error {
+		count++
+		return uc.enqueue(ctx, msgID)
+	})
+	if err != nil {
+		return fmt.Errorf("list inbox: %w", err)
+	}
+
+	log.Printf("Found %d messages to process", count)
+
+	if err := uc.checkpoints.SaveHistoryID(ctx, currentID); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (uc *SyncMailboxUseCase) enqueue(ctx context.Context, msgID string) error {
+	if err := uc.queue.Enqueue(ctx, msgID); err != nil {
+		return fmt.Errorf("enqueue %s: %w", msgID, err)
+	}
+	return nil
+}
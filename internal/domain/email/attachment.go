@@ -0,0 +1,30 @@
+package email
+
+// Attachment describes a file attached to an email. Text is only set once the
+// attachment has been read.
+type Attachment struct {
+	ID       string // provider attachment ID, empty when the content was inline
+	Filename string
+	MimeType string
+	Size     int64
+	Text     string
+}
+
+// AttachmentsText returns the extracted attachment text, each attachment
+// headed by its filename and the whole excerpt cut after limit runes
+func (e *Email) AttachmentsText(limit int) string {
+	var text []rune
+	for _, a := range e.Attachments {
+		if a.Text == "" {
+			continue
+		}
+		if len(text) > 0 {
+			text = append(text, '\n', '\n')
+		}
+		text = append(text, []rune("[Attachment: "+a.Filename+"]\n"+a.Text)...)
+		if len(text) >= limit {
+			return string(text[:limit])
+		}
+	}
+	return string(text)
+}
@@ -1,10 +1,35 @@
 package email
 
+import (
+	"fmt"
+	"time"
+)
+
+// Source says what decided an email's category and label
+type Source string
+
+const (
+	SourceRule   Source = "rule"
+	SourceLLM    Source = "llm"
+	SourceManual Source = "manual"
+	// SourceSender applies the label the user last corrected the sender to
+	SourceSender Source = "sender"
+)
+
 type Classification struct {
 	Category   Category
 	Label      Label
 	Reply      string
 	SenderName string
+
+	// Provenance for the classification history; the LLM fields stay empty
+	// when no model was asked
+	Source        Source
+	Model         string
+	PromptVersion string
+	Latency       time.Duration
+	InputTokens   int
+	OutputTokens  int
 }
 
 func NewClassification(category Category, label Label, reply, senderName string) *Classification {
@@ -15,3 +40,48 @@ func NewClassification(category Category, label Label, reply, senderName string)
 		SenderName: senderName,
 	}
 }
+
+// Validate checks that category and label are known values and that a reply
+// is present exactly when one is needed
+func (c *Classification) Validate() error {
+	if !c.Category.IsValid() {
+		return fmt.Errorf("invalid category %q", c.Category)
+	}
+	if !c.Label.IsValid() {
+		return fmt.Errorf("invalid label %q", c.Label)
+	}
+	if c.Category == CategoryActionNeeded && c.Reply == "" {
+		return fmt.Errorf("reply is required for category %q", c.Category)
+	}
+	return nil
+}
+
+// ClassificationRecord is one entry in an email's classification history
+type ClassificationRecord struct {
+	GmailID       string
+	Category      Category
+	Label         Label
+	Source        Source
+	Model         string
+	PromptVersion string
+	Latency       time.Duration
+	InputTokens   int
+	OutputTokens  int
+	CreatedAt     time.Time
+}
+
+// NewClassificationRecord records the classification of an email now
+func NewClassificationRecord(gmailID string, c *Classification) *ClassificationRecord {
+	return &ClassificationRecord{
+		GmailID:       gmailID,
+		Category:      c.Category,
+		Label:         c.Label,
+		Source:        c.Source,
+		Model:         c.Model,
+		PromptVersion: c.PromptVersion,
+		Latency:       c.Latency,
+		InputTokens:   c.InputTokens,
+		OutputTokens:  c.OutputTokens,
+		CreatedAt:     time.Now(),
+	}
+}
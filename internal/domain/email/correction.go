@@ -0,0 +1,23 @@
+package email
+
+import "time"
+
+// Correction is the user adding or removing one of the assistant's labels on
+// an email it already classified
+type Correction struct {
+	GmailID   string
+	Sender    string
+	Label     Label
+	Added     bool
+	CreatedAt time.Time
+}
+
+func NewCorrection(e *Email, label Label, added bool) *Correction {
+	return &Correction{
+		GmailID:   e.GmailID,
+		Sender:    e.SenderAddress(),
+		Label:     label,
+		Added:     added,
+		CreatedAt: time.Now(),
+	}
+}
@@ -1,16 +1,32 @@
 package email
 
-import "time"
+import (
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
 
 type Email struct {
-	ID        string
-	GmailID   string
-	From      string
-	Subject   string
-	Body      string
-	Category  Category
-	Label     Label
-	CreatedAt time.Time
+	ID          string
+	GmailID     string
+	ThreadID    string
+	MessageID   string
+	References  string
+	Headers     map[string]string
+	From        string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+	Category    Category
+	Label       Label
+	CreatedAt   time.Time
+
+	// LabelPending and PendingReply are the mailbox changes a stored
+	// classification still has to make, so a retry finishes them without
+	// classifying again
+	LabelPending bool
+	PendingReply string
 }
 
 func NewEmail(gmailID, from, subject, body string) *Email {
@@ -28,6 +44,52 @@ func (e *Email) Classify(category Category, label Label) {
 	e.Label = label
 }
 
+// Pending reports whether the classification still has to reach the mailbox
+func (e *Email) Pending() bool {
+	return e.LabelPending || e.PendingReply != ""
+}
+
 func (e *Email) NeedsReply() bool {
 	return e.Category == CategoryActionNeeded
 }
+
+// ReplySubject returns the subject for a reply, adding "Re: " only once
+func (e *Email) ReplySubject() string {
+	if strings.HasPrefix(strings.ToLower(e.Subject), "re:") {
+		return e.Subject
+	}
+	return "Re: " + e.Subject
+}
+
+// ReplyReferences returns the References chain for a reply to this email
+func (e *Email) ReplyReferences() string {
+	if e.MessageID == "" {
+		return e.References
+	}
+	if e.References == "" {
+		return e.MessageID
+	}
+	return e.References + " " + e.MessageID
+}
+
+// Header returns a header value by case-insensitive name
+func (e *Email) Header(name string) string {
+	return e.Headers[textproto.CanonicalMIMEHeaderKey(name)]
+}
+
+// SenderAddress returns the bare, lower-cased address from the From header
+func (e *Email) SenderAddress() string {
+	if addr, err := mail.ParseAddress(e.From); err == nil {
+		return strings.ToLower(addr.Address)
+	}
+	return strings.ToLower(strings.Trim(strings.TrimSpace(e.From), "<>"))
+}
+
+// SenderDomain returns the domain part of the sender address
+func (e *Email) SenderDomain() string {
+	addr := e.SenderAddress()
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return addr[i+1:]
+	}
+	return ""
+}
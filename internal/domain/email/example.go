@@ -0,0 +1,11 @@
+package email
+
+// Example is an email whose label the user confirmed, shown to the LLM as a
+// few-shot example. Body is an excerpt.
+type Example struct {
+	From     string
+	Subject  string
+	Body     string
+	Category Category
+	Label    Label
+}
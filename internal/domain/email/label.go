@@ -33,3 +33,25 @@ func (l Label) IsValid() bool {
 func (l Label) String() string {
 	return string(l)
 }
+
+func (c Category) IsValid() bool {
+	switch c {
+	case CategoryNewsletter, CategoryPrivate, CategoryBusiness, CategoryPayments, CategoryActionNeeded, CategoryJunk:
+		return true
+	}
+	return false
+}
+
+func (c Category) String() string {
+	return string(c)
+}
+
+// Labels returns every valid label
+func Labels() []Label {
+	return []Label{LabelNewsletter, LabelPrivate, LabelBusiness, LabelPayments, LabelActionNeeded, LabelJunk}
+}
+
+// Categories returns every valid category
+func Categories() []Category {
+	return []Category{CategoryNewsletter, CategoryPrivate, CategoryBusiness, CategoryPayments, CategoryActionNeeded, CategoryJunk}
+}
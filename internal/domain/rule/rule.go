@@ -0,0 +1,84 @@
+package rule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mailassist/internal/domain/email"
+)
+
+// Rule classifies emails matching all of its configured matchers
+type Rule struct {
+	Name string
+
+	// Matchers; empty ones are ignored, the rest must all match
+	Sender  string
+	Domain  string
+	ListID  string
+	Subject *regexp.Regexp
+	Headers map[string]*regexp.Regexp
+
+	Category email.Category
+	Label    email.Label
+	SkipLLM  bool
+}
+
+// Validate checks that the rule has a name, a matcher and a valid outcome
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if r.Sender == "" && r.Domain == "" && r.ListID == "" && r.Subject == nil && len(r.Headers) == 0 {
+		return fmt.Errorf("rule %q has no matchers", r.Name)
+	}
+	if !r.Category.IsValid() {
+		return fmt.Errorf("rule %q: invalid category %q", r.Name, r.Category)
+	}
+	if !r.Label.IsValid() {
+		return fmt.Errorf("rule %q: invalid label %q", r.Name, r.Label)
+	}
+	return nil
+}
+
+// Matches reports whether e satisfies every matcher of the rule
+func (r *Rule) Matches(e *email.Email) bool {
+	if r.Sender != "" && !strings.EqualFold(e.SenderAddress(), r.Sender) {
+		return false
+	}
+	if r.Domain != "" && !matchesDomain(e.SenderDomain(), r.Domain) {
+		return false
+	}
+	if r.ListID != "" && !strings.Contains(strings.ToLower(e.Header("List-Id")), strings.ToLower(r.ListID)) {
+		return false
+	}
+	if r.Subject != nil && !r.Subject.MatchString(e.Subject) {
+		return false
+	}
+	for name, re := range r.Headers {
+		if !re.MatchString(e.Header(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesDomain accepts the domain itself and any of its subdomains
+func matchesDomain(domain, want string) bool {
+	domain = strings.ToLower(domain)
+	want = strings.ToLower(strings.TrimPrefix(want, "@"))
+	return domain == want || strings.HasSuffix(domain, "."+want)
+}
+
+// Set is an ordered list of rules; the first match wins
+type Set []*Rule
+
+// Match returns the first rule matching e, or nil
+func (s Set) Match(e *email.Email) *Rule {
+	for _, r := range s {
+		if r.Matches(e) {
+			return r
+		}
+	}
+	return nil
+}
@@ -1,29 +1,123 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	MailBackendGmail = "gmail"
+	MailBackendIMAP  = "imap"
+	MailBackendGraph = "graph"
+)
+
+const (
+	PubSubModePull = "pull"
+	PubSubModePush = "push"
+	// PubSubModePoll polls History.List instead of using Pub/Sub
+	PubSubModePoll = "poll"
+)
+
+// Account is a Gmail mailbox served by this deployment
+type Account struct {
+	// ID is the mailbox's email address, or "me" for a single unnamed account
+	ID        string
+	TokenPath string
+}
+
 type Config struct {
-	// OpenAI
-	OpenAIAPIKey string
-	ModelName    string
+	// Mailbox backend and the accounts it serves
+	MailBackend string
+	Accounts    []Account
+
+	// Gmail OAuth client and token encryption (AES-256 key). The key is
+	// required unless ALLOW_PLAINTEXT_TOKENS opts out; nil stores tokens
+	// unencrypted.
+	CredentialsPath string
+	TokenKey        []byte
+
+	// Service account key with domain-wide delegation; when set, every Gmail
+	// account is impersonated instead of authorized through OAuth
+	ServiceAccountKeyPath string
+
+	// IMAP backend, watched with IDLE instead of Pub/Sub
+	IMAPAddr          string
+	IMAPUsername      string
+	IMAPPassword      string
+	IMAPTLS           bool
+	IMAPMailbox       string
+	IMAPDraftsMailbox string
+	IMAPLabelMode     string
+
+	// Microsoft Graph backend, synced with delta queries every PollInterval
+	GraphTenantID     string
+	GraphClientID     string
+	GraphClientSecret string
+	GraphBaseURL      string
+
+	// LLM
+	LLMProvider string
+	LLMAPIKey   string
+	LLMBaseURL  string
+	ModelName   string
+
+	// Few-shot examples from the user's confirmed labels; a budget of 0
+	// turns them off
+	FewShotExamples    int
+	FewShotTokenBudget int
 
 	// Google Cloud
 	GoogleCloudProject string
 	SubscriptionID     string
 	TopicName          string
 
+	// Change source: Pub/Sub streaming pull, HTTP push, or polling
+	PubSubMode         string
+	PollInterval       time.Duration
+	PushListenAddr     string
+	PushPath           string
+	PushVerifyToken    bool
+	PushAudience       string
+	PushServiceAccount string
+
+	// Pub/Sub notification dedup
+	DedupTTL        time.Duration
+	DedupMaxEntries int
+	DedupPersist    bool
+
+	// Gmail watch
+	WatchRenewBefore    time.Duration
+	StopWatchOnShutdown bool
+
 	// Database
 	DatabasePath string
 
+	// Rules evaluated before the LLM
+	RulesPath string
+
 	// App settings
 	NumWorkers           int
 	InitialEmailsToFetch int64
+	ResyncMaxMessages    int64
+
+	// Upstream rate limits
+	GmailQuotaUnitsPerSecond float64
+	LLMRequestsPerMinute     int
+	LLMTokensPerMinute       int
+
+	// Job queue and retries
+	JobVisibilityTimeout time.Duration
+	JobMaxAttempts       int
+	RetryBaseDelay       time.Duration
+	RetryMaxDelay        time.Duration
 }
 
 func Load() (*Config, error) {
@@ -32,34 +126,220 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		OpenAIAPIKey:         getEnv("OPENAI_API_KEY", ""),
-		ModelName:            getEnv("MODEL_NAME", "gpt-4o-mini"),
-		GoogleCloudProject:   getEnv("GOOGLE_CLOUD_PROJECT", ""),
-		SubscriptionID:       getEnv("SUBSCRIPTION_ID", ""),
-		DatabasePath:         getEnv("DATABASE_PATH", "mailai.db"),
-		NumWorkers:           5,
-		InitialEmailsToFetch: 20,
+		MailBackend:           getEnv("MAIL_BACKEND", MailBackendGmail),
+		CredentialsPath:       getEnv("CREDENTIALS_PATH", "credentials.json"),
+		ServiceAccountKeyPath: getEnv("GMAIL_SERVICE_ACCOUNT_KEY", ""),
+		IMAPAddr:              getEnv("IMAP_ADDR", ""),
+		IMAPUsername:          getEnv("IMAP_USERNAME", ""),
+		IMAPPassword:          getEnv("IMAP_PASSWORD", ""),
+		IMAPTLS:               getEnvBool("IMAP_TLS", true),
+		IMAPMailbox:           getEnv("IMAP_MAILBOX", "INBOX"),
+		IMAPDraftsMailbox:     getEnv("IMAP_DRAFTS_MAILBOX", "Drafts"),
+		IMAPLabelMode:         getEnv("IMAP_LABEL_MODE", "keyword"),
+		GraphTenantID:         getEnv("GRAPH_TENANT_ID", ""),
+		GraphClientID:         getEnv("GRAPH_CLIENT_ID", ""),
+		GraphClientSecret:     getEnv("GRAPH_CLIENT_SECRET", ""),
+		GraphBaseURL:          getEnv("GRAPH_BASE_URL", ""),
+		LLMProvider:           getEnv("LLM_PROVIDER", "openai"),
+		LLMBaseURL:            getEnv("LLM_BASE_URL", ""),
+		ModelName:             getEnv("MODEL_NAME", ""),
+		GoogleCloudProject:    getEnv("GOOGLE_CLOUD_PROJECT", ""),
+		SubscriptionID:        getEnv("SUBSCRIPTION_ID", ""),
+		DatabasePath:          getEnv("DATABASE_PATH", "mailai.db"),
+		RulesPath:             getEnv("RULES_PATH", "rules.json"),
+		PubSubMode:            getEnv("PUBSUB_MODE", PubSubModePull),
+		PollInterval:          getEnvDuration("POLL_INTERVAL", time.Minute),
+		PushListenAddr:        getEnv("PUSH_LISTEN_ADDR", ":8080"),
+		PushPath:              getEnv("PUSH_PATH", "/pubsub/push"),
+		PushVerifyToken:       getEnvBool("PUSH_VERIFY_TOKEN", true),
+		PushAudience:          getEnv("PUSH_AUDIENCE", ""),
+		PushServiceAccount:    getEnv("PUSH_SERVICE_ACCOUNT", ""),
+		DedupTTL:              24 * time.Hour,
+		DedupMaxEntries:       10000,
+		DedupPersist:          getEnvBool("DEDUP_PERSIST", false),
+		WatchRenewBefore:      getEnvDuration("WATCH_RENEW_BEFORE", 24*time.Hour),
+		StopWatchOnShutdown:   getEnvBool("STOP_WATCH_ON_SHUTDOWN", false),
+		NumWorkers:            5,
+		InitialEmailsToFetch:  20,
+		ResyncMaxMessages:     500,
+		// Gmail allows 250 quota units per user per second
+		GmailQuotaUnitsPerSecond: float64(getEnvInt("GMAIL_QUOTA_UNITS_PER_SECOND", 200)),
+		LLMRequestsPerMinute:     getEnvInt("LLM_REQUESTS_PER_MINUTE", 500),
+		LLMTokensPerMinute:       getEnvInt("LLM_TOKENS_PER_MINUTE", 200000),
+		FewShotExamples:          getEnvInt("FEW_SHOT_EXAMPLES", 3),
+		FewShotTokenBudget:       getEnvInt("FEW_SHOT_TOKEN_BUDGET", 1000),
+		JobVisibilityTimeout:     5 * time.Minute,
+		JobMaxAttempts:           5,
+		RetryBaseDelay:           2 * time.Second,
+		RetryMaxDelay:            5 * time.Minute,
 	}
 
 	// Validate required fields
-	if cfg.OpenAIAPIKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY is required")
+	switch cfg.LLMProvider {
+	case "openai":
+		cfg.LLMAPIKey = getEnv("OPENAI_API_KEY", "")
+		if cfg.LLMAPIKey == "" && cfg.LLMBaseURL == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY is required")
+		}
+	case "anthropic":
+		cfg.LLMAPIKey = getEnv("ANTHROPIC_API_KEY", "")
+		if cfg.LLMAPIKey == "" {
+			return nil, fmt.Errorf("ANTHROPIC_API_KEY is required")
+		}
+	}
+
+	switch cfg.MailBackend {
+	case MailBackendGmail:
+		cfg.Accounts = loadAccounts(getEnv("GMAIL_ACCOUNTS", ""), getEnv("TOKEN_DIR", "tokens"), getEnv("TOKEN_PATH", "token.json"))
+		if cfg.ServiceAccountKeyPath != "" {
+			// The impersonated users must be named; "me" means nobody
+			if getEnv("GMAIL_ACCOUNTS", "") == "" {
+				return nil, fmt.Errorf("GMAIL_ACCOUNTS is required with GMAIL_SERVICE_ACCOUNT_KEY")
+			}
+		} else {
+			key, err := loadTokenKey(getEnv("TOKEN_ENCRYPTION_KEY", ""), getEnv("TOKEN_ENCRYPTION_KEY_FILE", ""))
+			if err != nil {
+				return nil, err
+			}
+			if key == nil {
+				if !getEnvBool("ALLOW_PLAINTEXT_TOKENS", false) {
+					return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY or TOKEN_ENCRYPTION_KEY_FILE is required, set ALLOW_PLAINTEXT_TOKENS=true to store OAuth tokens unencrypted")
+				}
+				log.Println("Warning: ALLOW_PLAINTEXT_TOKENS is set, OAuth tokens are stored unencrypted")
+			}
+			cfg.TokenKey = key
+		}
+	case MailBackendIMAP:
+		if cfg.IMAPAddr == "" || cfg.IMAPUsername == "" || cfg.IMAPPassword == "" {
+			return nil, fmt.Errorf("IMAP_ADDR, IMAP_USERNAME and IMAP_PASSWORD are required")
+		}
+		if cfg.IMAPLabelMode != "keyword" && cfg.IMAPLabelMode != "folder" {
+			return nil, fmt.Errorf("unknown IMAP_LABEL_MODE %q", cfg.IMAPLabelMode)
+		}
+		cfg.Accounts = []Account{{ID: strings.ToLower(cfg.IMAPUsername)}}
+		// IMAP is watched with IDLE, none of the Google Cloud settings apply
+		return cfg, nil
+	case MailBackendGraph:
+		if cfg.GraphTenantID == "" || cfg.GraphClientID == "" || cfg.GraphClientSecret == "" {
+			return nil, fmt.Errorf("GRAPH_TENANT_ID, GRAPH_CLIENT_ID and GRAPH_CLIENT_SECRET are required")
+		}
+		for _, user := range strings.Split(getEnv("GRAPH_USERS", ""), ",") {
+			if user = strings.ToLower(strings.TrimSpace(user)); user != "" {
+				cfg.Accounts = append(cfg.Accounts, Account{ID: user})
+			}
+		}
+		if len(cfg.Accounts) == 0 {
+			return nil, fmt.Errorf("GRAPH_USERS is required")
+		}
+		if cfg.PollInterval <= 0 {
+			return nil, fmt.Errorf("POLL_INTERVAL must be positive")
+		}
+		// Graph mailboxes are polled, none of the Google Cloud settings apply
+		return cfg, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_BACKEND %q", cfg.MailBackend)
 	}
-	if cfg.GoogleCloudProject == "" {
+
+	if cfg.GoogleCloudProject == "" && cfg.PubSubMode != PubSubModePoll {
 		return nil, fmt.Errorf("GOOGLE_CLOUD_PROJECT is required")
 	}
-	if cfg.SubscriptionID == "" {
-		return nil, fmt.Errorf("SUBSCRIPTION_ID is required")
+	switch cfg.PubSubMode {
+	case PubSubModePoll:
+		if cfg.PollInterval <= 0 {
+			return nil, fmt.Errorf("POLL_INTERVAL must be positive")
+		}
+	case PubSubModePull:
+		if cfg.SubscriptionID == "" {
+			return nil, fmt.Errorf("SUBSCRIPTION_ID is required")
+		}
+	case PubSubModePush:
+		if cfg.PushVerifyToken && cfg.PushAudience == "" {
+			return nil, fmt.Errorf("PUSH_AUDIENCE is required when PUSH_VERIFY_TOKEN is enabled")
+		}
+	default:
+		return nil, fmt.Errorf("unknown PUBSUB_MODE %q", cfg.PubSubMode)
+	}
+	// Gmail watches last 7 days; renewing any earlier would never wait
+	if cfg.PubSubMode != PubSubModePoll && (cfg.WatchRenewBefore <= 0 || cfg.WatchRenewBefore >= 7*24*time.Hour) {
+		return nil, fmt.Errorf("WATCH_RENEW_BEFORE must be positive and less than 7 days")
 	}
 
-	cfg.TopicName = fmt.Sprintf("projects/%s/topics/gmail-topic", cfg.GoogleCloudProject)
+	if cfg.GoogleCloudProject != "" {
+		cfg.TopicName = fmt.Sprintf("projects/%s/topics/gmail-topic", cfg.GoogleCloudProject)
+	}
 
 	return cfg, nil
 }
 
+// loadAccounts parses a comma-separated list of addresses, each with its own
+// token file in tokenDir. Without a list, the single account authorized by
+// the token at tokenPath is served.
+func loadAccounts(list, tokenDir, tokenPath string) []Account {
+	var accounts []Account
+	for _, address := range strings.Split(list, ",") {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if address == "" {
+			continue
+		}
+		accounts = append(accounts, Account{
+			ID:        address,
+			TokenPath: filepath.Join(tokenDir, address+".json"),
+		})
+	}
+
+	if len(accounts) == 0 {
+		accounts = append(accounts, Account{ID: "me", TokenPath: tokenPath})
+	}
+
+	return accounts
+}
+
+// loadTokenKey reads the base64 encoded 32 byte token encryption key from the
+// environment or from a file
+func loadTokenKey(value, file string) ([]byte, error) {
+	if value == "" && file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read TOKEN_ENCRYPTION_KEY_FILE: %w", err)
+		}
+		value = strings.TrimSpace(string(b))
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("token encryption key must be 32 bytes, base64 encoded")
+	}
+
+	return key, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -5,16 +5,21 @@ import (
 	"encoding/base64"
 	"fmt"
 	"log"
+	"net/textproto"
 	"strings"
 
 	"google.golang.org/api/gmail/v1"
 	"mailassist/internal/domain/email"
+	"mailassist/internal/infrastructure/mailtext"
 )
 
 // Client implements Gmail operations (adapter)
 type Client struct {
 	Srv      *gmail.Service
 	LabelIDs map[string]string
+
+	// beforePage runs before every page request of a paged list call
+	beforePage func(ctx context.Context, method string) error
 }
 
 // NewClient creates a new Gmail client
@@ -25,6 +30,20 @@ func NewClient(srv *gmail.Service) *Client {
 	}
 }
 
+// OnPage registers a hook that runs before every page request of a paged
+// list call, e.g. to charge quota page by page. method is the API method,
+// "history.list" or "messages.list"; an error from the hook ends the listing.
+func (c *Client) OnPage(hook func(ctx context.Context, method string) error) {
+	c.beforePage = hook
+}
+
+func (c *Client) waitPage(ctx context.Context, method string) error {
+	if c.beforePage == nil {
+		return nil
+	}
+	return c.beforePage(ctx, method)
+}
+
 // labelMap maps domain labels to Gmail label names
 // TODO: check if this makes sense
 var labelMap = map[email.Label]string{
@@ -73,15 +92,42 @@ func (c *Client) InitLabels() error {
 func (c *Client) FetchEmail(ctx context.Context, messageID string) (*email.Email, error) {
 	msg, err := c.Srv.Users.Messages.Get("me", messageID).Format("FULL").Context(ctx).Do()
 	if err != nil {
-		return nil, fmt.Errorf("gmail get message: %w", err)
+		return nil, fmt.Errorf("gmail get message: %w", classifyError(err))
 	}
 
-	return email.NewEmail(
+	e := email.NewEmail(
 		messageID,
 		extractHeader(msg, "From"),
 		extractHeader(msg, "Subject"),
 		extractBody(msg),
-	), nil
+	)
+	e.Headers = extractHeaders(msg)
+	e.ThreadID = msg.ThreadId
+	e.MessageID = extractHeader(msg, "Message-ID")
+	e.References = extractHeader(msg, "References")
+	e.Attachments = extractAttachments(msg)
+
+	return e, nil
+}
+
+// AttachmentText downloads an attachment and extracts its text. Types without
+// text and oversized files are skipped without downloading.
+func (c *Client) AttachmentText(ctx context.Context, messageID string, a *email.Attachment) (string, error) {
+	if a.ID == "" || a.Size > mailtext.MaxAttachmentSize || !mailtext.CanExtractText(a.MimeType, a.Filename) {
+		return a.Text, nil
+	}
+
+	body, err := c.Srv.Users.Messages.Attachments.Get("me", messageID, a.ID).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("gmail get attachment: %w", classifyError(err))
+	}
+
+	data, err := decodeBase64URL(body.Data)
+	if err != nil {
+		return "", fmt.Errorf("decode attachment %s: %w", a.Filename, err)
+	}
+
+	return mailtext.AttachmentText(a.MimeType, a.Filename, data)
 }
 
 func (c *Client) ApplyLabel(ctx context.Context, messageID string, label email.Label) error {
@@ -96,81 +142,23 @@ func (c *Client) ApplyLabel(ctx context.Context, messageID string, label email.L
 		AddLabelIds: []string{labelID},
 	}).Context(ctx).Do()
 
-	return err
+	return classifyError(err)
 }
 
-func (c *Client) CreateDraft(ctx context.Context, recipient, subject, body string) error {
-	raw := fmt.Sprintf(
-		"To: %s\r\nSubject: %s\r\n\r\n%s",
-		recipient, subject, body,
-	)
+// CreateDraft creates a reply draft inside the thread of the original email
+func (c *Client) CreateDraft(ctx context.Context, original *email.Email, body string) error {
+	raw := mailtext.BuildReply("", original, body)
 
 	encoded := base64.URLEncoding.EncodeToString([]byte(raw))
 
 	_, err := c.Srv.Users.Drafts.Create("me", &gmail.Draft{
 		Message: &gmail.Message{
-			Raw: encoded,
+			Raw:      encoded,
+			ThreadId: original.ThreadID,
 		},
 	}).Context(ctx).Do()
 
-	return err
-}
-
-func (c *Client) FetchNewMessagesSince(ctx context.Context, historyID uint64) ([]string, error) {
-	var messageIDs []string
-
-	resp, err := c.Srv.Users.History.List("me").
-		StartHistoryId(historyID).
-		HistoryTypes("messageAdded").
-		Context(ctx).
-		Do()
-
-	if err != nil {
-		return nil, fmt.Errorf("gmail history list: %w", err)
-	}
-
-	for _, h := range resp.History {
-		for _, added := range h.MessagesAdded {
-			if added.Message != nil && !isDraft(added.Message) {
-				messageIDs = append(messageIDs, added.Message.Id)
-			}
-		}
-	}
-
-	return messageIDs, nil
-}
-
-// EnableWatch enables Gmail push notifications
-func (c *Client) EnableWatch(ctx context.Context, topicName string) error {
-	req := &gmail.WatchRequest{
-		TopicName: topicName,
-	}
-
-	_, err := c.Srv.Users.Watch("me", req).Context(ctx).Do()
-	if err != nil {
-		return fmt.Errorf("gmail watch: %w", err)
-	}
-
-	return nil
-}
-
-func (c *Client) ListMessagesFromInbox(ctx context.Context, maxResults int64) ([]string, error) {
-	resp, err := c.Srv.Users.Messages.List("me").
-		LabelIds("INBOX").
-		MaxResults(maxResults).
-		Context(ctx).
-		Do()
-
-	if err != nil {
-		return nil, fmt.Errorf("list messages: %w", err)
-	}
-
-	var ids []string
-	for _, msg := range resp.Messages {
-		ids = append(ids, msg.Id)
-	}
-
-	return ids, nil
+	return classifyError(err)
 }
 
 func extractHeader(msg *gmail.Message, name string) string {
@@ -182,19 +170,17 @@ func extractHeader(msg *gmail.Message, name string) string {
 	return ""
 }
 
-func extractBody(msg *gmail.Message) string {
-	if msg.Payload.Body != nil && msg.Payload.Body.Data != "" {
-		d, _ := base64.URLEncoding.DecodeString(msg.Payload.Body.Data)
-		return string(d)
-	}
-
-	for _, p := range msg.Payload.Parts {
-		if p.MimeType == "text/plain" && p.Body != nil && p.Body.Data != "" {
-			d, _ := base64.URLEncoding.DecodeString(p.Body.Data)
-			return string(d)
+// extractHeaders returns the top-level headers keyed by canonical name; for
+// repeated headers the first value wins
+func extractHeaders(msg *gmail.Message) map[string]string {
+	headers := make(map[string]string, len(msg.Payload.Headers))
+	for _, h := range msg.Payload.Headers {
+		key := textproto.CanonicalMIMEHeaderKey(h.Name)
+		if _, ok := headers[key]; !ok {
+			headers[key] = h.Value
 		}
 	}
-	return ""
+	return headers
 }
 
 func isDraft(msg *gmail.Message) bool {
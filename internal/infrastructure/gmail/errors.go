@@ -0,0 +1,33 @@
+package gmail
+
+import (
+	"errors"
+
+	"google.golang.org/api/googleapi"
+	"mailassist/internal/infrastructure/upstream"
+)
+
+// classifyError marks rate limits, backend errors and network failures as
+// transient so the job is retried
+func classifyError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		// Gmail reports per-user quota exhaustion as 403 rateLimitExceeded
+		if apiErr.Code == 403 && hasReason(apiErr, "rateLimitExceeded", "userRateLimitExceeded") {
+			return upstream.Classify(err, 429, apiErr.Header)
+		}
+		return upstream.Classify(err, apiErr.Code, apiErr.Header)
+	}
+	return upstream.Classify(err, 0, nil)
+}
+
+func hasReason(apiErr *googleapi.Error, reasons ...string) bool {
+	for _, item := range apiErr.Errors {
+		for _, r := range reasons {
+			if item.Reason == r {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	emailapp "mailassist/internal/application/email"
+	"mailassist/internal/domain/email"
+)
+
+// maxPageSize is the largest page Gmail returns for Messages.List
+const maxPageSize = 500
+
+// Paged list methods, as passed to the OnPage hook
+const (
+	methodHistoryList  = "history.list"
+	methodMessagesList = "messages.list"
+)
+
+// errStopPaging ends a Pages loop early without reporting an error
+var errStopPaging = errors.New("stop paging")
+
+// FetchNewMessagesSince pages through History.List from historyID and returns
+// the added message IDs together with the mailbox's latest historyId
+func (c *Client) FetchNewMessagesSince(ctx context.Context, historyID uint64) ([]string, uint64, error) {
+	var messageIDs []string
+
+	latestID, err := c.StreamNewMessagesSince(ctx, historyID, func(id string) error {
+		messageIDs = append(messageIDs, id)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return messageIDs, latestID, nil
+}
+
+// StreamNewMessagesSince calls fn for every added message as each history
+// page arrives and returns the mailbox's latest historyId
+func (c *Client) StreamNewMessagesSince(ctx context.Context, historyID uint64, fn func(gmailID string) error) (uint64, error) {
+	latestID := historyID
+
+	if err := c.waitPage(ctx, methodHistoryList); err != nil {
+		return 0, err
+	}
+
+	err := c.Srv.Users.History.List("me").
+		StartHistoryId(historyID).
+		HistoryTypes("messageAdded").
+		Pages(ctx, func(resp *gmail.ListHistoryResponse) error {
+			for _, h := range resp.History {
+				for _, added := range h.MessagesAdded {
+					if added.Message == nil || isDraft(added.Message) {
+						continue
+					}
+					if err := fn(added.Message.Id); err != nil {
+						return err
+					}
+				}
+			}
+			if resp.HistoryId > latestID {
+				latestID = resp.HistoryId
+			}
+			if resp.NextPageToken != "" {
+				return c.waitPage(ctx, methodHistoryList)
+			}
+			return ctx.Err()
+		})
+
+	if isNotFound(err) {
+		return 0, fmt.Errorf("gmail history list: %w", emailapp.ErrHistoryExpired)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("gmail history list: %w", classifyError(err))
+	}
+
+	return latestID, nil
+}
+
+// CurrentHistoryID returns the mailbox's latest historyId
+func (c *Client) CurrentHistoryID(ctx context.Context) (uint64, error) {
+	profile, err := c.Srv.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return 0, fmt.Errorf("gmail get profile: %w", classifyError(err))
+	}
+
+	return profile.HistoryId, nil
+}
+
+// EmailAddress returns the address of the authorized mailbox
+func (c *Client) EmailAddress(ctx context.Context) (string, error) {
+	profile, err := c.Srv.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("gmail get profile: %w", classifyError(err))
+	}
+
+	return profile.EmailAddress, nil
+}
+
+// ListMessagesFromInbox returns up to maxResults inbox message IDs, newest
+// first. A maxResults of 0 or less lists the whole inbox.
+func (c *Client) ListMessagesFromInbox(ctx context.Context, maxResults int64) ([]string, error) {
+	var ids []string
+
+	err := c.StreamInboxMessages(ctx, maxResults, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// StreamInboxMessages calls fn for up to maxResults inbox message IDs as
+// each page arrives
+func (c *Client) StreamInboxMessages(ctx context.Context, maxResults int64, fn func(gmailID string) error) error {
+	pageSize := int64(maxPageSize)
+	if maxResults > 0 && maxResults < pageSize {
+		pageSize = maxResults
+	}
+
+	if err := c.waitPage(ctx, methodMessagesList); err != nil {
+		return err
+	}
+
+	var seen int64
+	err := c.Srv.Users.Messages.List("me").
+		LabelIds("INBOX").
+		MaxResults(pageSize).
+		Pages(ctx, func(resp *gmail.ListMessagesResponse) error {
+			for _, msg := range resp.Messages {
+				if maxResults > 0 && seen >= maxResults {
+					return errStopPaging
+				}
+				if err := fn(msg.Id); err != nil {
+					return err
+				}
+				seen++
+			}
+			if maxResults > 0 && seen >= maxResults {
+				return errStopPaging
+			}
+			if resp.NextPageToken != "" {
+				return c.waitPage(ctx, methodMessagesList)
+			}
+			return ctx.Err()
+		})
+
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return fmt.Errorf("list messages: %w", classifyError(err))
+	}
+
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// StreamLabelChangesSince calls fn for every one of our labels added to or
+// removed from a message after historyID, stopping at records past untilID
+func (c *Client) StreamLabelChangesSince(ctx context.Context, historyID, untilID uint64, fn func(emailapp.LabelChange) error) error {
+	managed := make(map[string]email.Label, len(labelMap))
+	for label, gmailName := range labelMap {
+		if id := c.LabelIDs[gmailName]; id != "" {
+			managed[id] = label
+		}
+	}
+
+	emit := func(msg *gmail.Message, labelIDs []string, added bool) error {
+		if msg == nil {
+			return nil
+		}
+		for _, id := range labelIDs {
+			label, ok := managed[id]
+			if !ok {
+				continue
+			}
+			if err := fn(emailapp.LabelChange{GmailID: msg.Id, Label: label, Added: added}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := c.waitPage(ctx, methodHistoryList); err != nil {
+		return err
+	}
+
+	err := c.Srv.Users.History.List("me").
+		StartHistoryId(historyID).
+		HistoryTypes("labelAdded", "labelRemoved").
+		Pages(ctx, func(resp *gmail.ListHistoryResponse) error {
+			for _, h := range resp.History {
+				if h.Id > untilID {
+					return errStopPaging
+				}
+				for _, removed := range h.LabelsRemoved {
+					if err := emit(removed.Message, removed.LabelIds, false); err != nil {
+						return err
+					}
+				}
+				for _, added := range h.LabelsAdded {
+					if err := emit(added.Message, added.LabelIds, true); err != nil {
+						return err
+					}
+				}
+			}
+			if resp.NextPageToken != "" {
+				return c.waitPage(ctx, methodHistoryList)
+			}
+			return ctx.Err()
+		})
+
+	if isNotFound(err) {
+		return fmt.Errorf("gmail label history: %w", emailapp.ErrHistoryExpired)
+	}
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return fmt.Errorf("gmail label history: %w", classifyError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"google.golang.org/api/gmail/v1"
+	"mailassist/internal/domain/email"
+	"mailassist/internal/infrastructure/mailtext"
+)
+
+// extractBody walks the MIME tree and returns the best readable body as UTF-8.
+// text/plain wins over text/html; HTML is converted to text only as a fallback.
+func extractBody(msg *gmail.Message) string {
+	if msg.Payload == nil {
+		return ""
+	}
+
+	var plain, htmlBody string
+	walkParts(msg.Payload, func(p *gmail.MessagePart) {
+		mediaType, params := partContentType(p)
+
+		switch mediaType {
+		case "text/plain":
+			if plain == "" {
+				plain = strings.TrimSpace(decodePart(p, params["charset"]))
+			}
+		case "text/html":
+			if htmlBody == "" {
+				htmlBody = decodePart(p, params["charset"])
+			}
+		}
+	})
+
+	if plain != "" {
+		return plain
+	}
+	if htmlBody != "" {
+		return mailtext.HTMLToText(htmlBody)
+	}
+	return ""
+}
+
+// walkParts visits every leaf part depth-first, skipping attachments
+func walkParts(p *gmail.MessagePart, visit func(*gmail.MessagePart)) {
+	if p == nil {
+		return
+	}
+
+	if len(p.Parts) > 0 {
+		for _, child := range p.Parts {
+			walkParts(child, visit)
+		}
+		return
+	}
+
+	if isAttachment(p) {
+		return
+	}
+	visit(p)
+}
+
+// extractAttachments lists the attachments of the message. Gmail inlines the
+// body of small attachments instead of giving them an ID; their text is
+// extracted right away since it is already downloaded.
+func extractAttachments(msg *gmail.Message) []email.Attachment {
+	var attachments []email.Attachment
+	walkAttachments(msg.Payload, func(p *gmail.MessagePart) {
+		mediaType, _ := partContentType(p)
+		a := email.Attachment{
+			Filename: p.Filename,
+			MimeType: mediaType,
+		}
+		if p.Body != nil {
+			a.ID = p.Body.AttachmentId
+			a.Size = p.Body.Size
+		}
+
+		if a.ID == "" && p.Body != nil && p.Body.Data != "" && mailtext.CanExtractText(a.MimeType, a.Filename) {
+			if data, err := decodeBase64URL(p.Body.Data); err == nil {
+				a.Text, _ = mailtext.AttachmentText(a.MimeType, a.Filename, data)
+			}
+		}
+
+		attachments = append(attachments, a)
+	})
+	return attachments
+}
+
+// walkAttachments visits every leaf part that is an attachment
+func walkAttachments(p *gmail.MessagePart, visit func(*gmail.MessagePart)) {
+	if p == nil {
+		return
+	}
+
+	if len(p.Parts) > 0 {
+		for _, child := range p.Parts {
+			walkAttachments(child, visit)
+		}
+		return
+	}
+
+	if isAttachment(p) {
+		visit(p)
+	}
+}
+
+func isAttachment(p *gmail.MessagePart) bool {
+	if p.Filename != "" {
+		return true
+	}
+	disposition, _, _ := mime.ParseMediaType(partHeader(p, "Content-Disposition"))
+	return disposition == "attachment"
+}
+
+func partHeader(p *gmail.MessagePart, name string) string {
+	for _, h := range p.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func partContentType(p *gmail.MessagePart) (string, map[string]string) {
+	if ct := partHeader(p, "Content-Type"); ct != "" {
+		if mediaType, params, err := mime.ParseMediaType(ct); err == nil {
+			return mediaType, params
+		}
+	}
+	return strings.ToLower(p.MimeType), map[string]string{}
+}
+
+// decodePart returns the part body as UTF-8 text. Gmail delivers body data
+// with the Content-Transfer-Encoding already undone, so only the charset is
+// converted.
+func decodePart(p *gmail.MessagePart, charset string) string {
+	if p.Body == nil || p.Body.Data == "" {
+		return ""
+	}
+
+	data, err := decodeBase64URL(p.Body.Data)
+	if err != nil {
+		return ""
+	}
+
+	return toUTF8(data, charset)
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	if d, err := base64.URLEncoding.DecodeString(s); err == nil {
+		return d, nil
+	}
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// toUTF8 converts data from the given charset, falling back to the raw bytes
+func toUTF8(data []byte, charset string) string {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		return string(data)
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(data)
+	}
+
+	d, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(d)
+}
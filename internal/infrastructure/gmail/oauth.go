@@ -2,46 +2,94 @@ package gmail
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
-	"log"
-	"os"
+	emailapp "mailassist/internal/application/email"
 )
 
-func NewService(ctx context.Context) (*gmail.Service, error) {
-	// 1. Load credentials.json from Google Cloud
-	b, err := os.ReadFile("credentials.json")
+// authTimeout bounds how long the OAuth flow waits for the browser
+const authTimeout = 5 * time.Minute
+
+// scopes are the Gmail permissions the assistant needs
+var scopes = []string{
+	gmail.GmailModifyScope,
+	gmail.GmailLabelsScope,
+	gmail.GmailComposeScope,
+}
+
+// OAuthConfig says how an account is authorized: either with a service
+// account key and domain-wide delegation, or with a user OAuth client and a
+// stored token
+type OAuthConfig struct {
+	// ServiceAccountKeyPath and Subject impersonate Subject in a Workspace
+	// domain; the key's client ID must be granted the scopes in the admin
+	// console
+	ServiceAccountKeyPath string
+	Subject               string
+
+	CredentialsPath string
+	Tokens          TokenStore
+	// LoginHint preselects the Google account on the consent screen
+	LoginHint string
+}
+
+// NewService authorizes the account. With a user OAuth client the OAuth flow
+// runs when no token is stored yet, and tokens refreshed later are written
+// back to the store.
+func NewService(ctx context.Context, cfg OAuthConfig) (*gmail.Service, error) {
+	if cfg.ServiceAccountKeyPath != "" {
+		return newDelegatedService(ctx, cfg.ServiceAccountKeyPath, cfg.Subject)
+	}
+
+	// 1. Load the OAuth client from Google Cloud
+	b, err := os.ReadFile(cfg.CredentialsPath)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read credentials.json: %w", err)
+		return nil, fmt.Errorf("cannot read %s: %w", cfg.CredentialsPath, err)
 	}
 
 	// 2. Configure OAuth with required scopes
-	config, err := google.ConfigFromJSON(b,
-		gmail.GmailModifyScope,
-		gmail.GmailLabelsScope,
-		gmail.GmailComposeScope,
-	)
+	config, err := google.ConfigFromJSON(b, scopes...)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse credentials.json: %w", err)
+		return nil, fmt.Errorf("cannot parse %s: %w", cfg.CredentialsPath, err)
 	}
 
-	// 3. Get token from file or start OAuth flow
-	tok, err := tokenFromFile("token.json")
-	if err != nil {
-		log.Println("token.json not found – starting OAuth flow")
-		tok, err = getTokenFromWeb(config)
+	// 3. Get token from the store or start the OAuth flow
+	tok, err := cfg.Tokens.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		log.Println("No stored token – starting OAuth flow")
+		tok, err = getTokenFromWeb(ctx, config, cfg.LoginHint)
 		if err != nil {
 			return nil, err
 		}
-		saveToken("token.json", tok)
+		if err := cfg.Tokens.Save(tok); err != nil {
+			return nil, fmt.Errorf("cannot save token: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load token: %w", err)
 	}
 
 	// 4. Create Gmail Service
-	srv, err := gmail.NewService(ctx, option.WithTokenSource(config.TokenSource(ctx, tok)))
+	tokens := &persistingTokenSource{
+		src:   config.TokenSource(ctx, tok),
+		store: cfg.Tokens,
+		last:  tok,
+	}
+	srv, err := gmail.NewService(ctx, option.WithTokenSource(tokens))
 	if err != nil {
 		return nil, fmt.Errorf("cannot create gmail service: %w", err)
 	}
@@ -49,47 +97,147 @@ func NewService(ctx context.Context) (*gmail.Service, error) {
 	return srv, nil
 }
 
-func getTokenFromWeb(config *oauth2.Config) (*oauth2.Token, error) {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Println("1) Copy this URL and open it in your browser:")
-	fmt.Println(authURL)
-	fmt.Println("\n2) Sign in and accept the permissions.")
-	fmt.Print("3) Paste the authorization code here: ")
+// newDelegatedService acts as subject through a service account with
+// domain-wide delegation; no user interaction or stored token is needed
+func newDelegatedService(ctx context.Context, keyPath, subject string) (*gmail.Service, error) {
+	if subject == "" {
+		return nil, fmt.Errorf("domain-wide delegation needs the user to impersonate")
+	}
 
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		return nil, fmt.Errorf("cannot read auth code: %w", err)
+	b, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", keyPath, err)
 	}
 
-	tok, err := config.Exchange(context.Background(), authCode)
+	config, err := google.JWTConfigFromJSON(b, scopes...)
 	if err != nil {
-		return nil, fmt.Errorf("cannot exchange code for token: %w", err)
+		return nil, fmt.Errorf("cannot parse %s: %w", keyPath, err)
 	}
-	return tok, nil
+	config.Subject = subject
+
+	srv, err := gmail.NewService(ctx, option.WithTokenSource(config.TokenSource(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gmail service: %w", err)
+	}
+
+	return srv, nil
 }
 
-func tokenFromFile(path string) (*oauth2.Token, error) {
-	f, err := os.Open(path)
+// getTokenFromWeb runs the authorization code flow with a loopback redirect,
+// a random state and PKCE
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config, loginHint string) (*oauth2.Token, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("cannot start OAuth callback listener: %w", err)
+	}
+	defer listener.Close()
+
+	state, err := randomState()
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	verifier := oauth2.GenerateVerifier()
+
+	flow := *config
+	flow.RedirectURL = fmt.Sprintf("http://%s/callback", listener.Addr())
+
+	opts := []oauth2.AuthCodeOption{
+		oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(verifier),
+		// Ask for consent so Google issues a refresh token again
+		oauth2.SetAuthURLParam("prompt", "consent"),
+	}
+	if loginHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("login_hint", loginHint))
+	}
+
+	fmt.Println("Open this URL in your browser and accept the permissions:")
+	fmt.Println(flow.AuthCodeURL(state, opts...))
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(state)) != 1 {
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+		if msg := q.Get("error"); msg != "" {
+			http.Error(w, "Authorization failed: "+msg, http.StatusBadRequest)
+			errs <- fmt.Errorf("authorization failed: %s", msg)
+			return
+		}
+		code := q.Get("code")
+		if code == "" {
+			http.Error(w, "Missing code", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "MailAssist is authorized. You can close this tab.")
+		select {
+		case codes <- code:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, authTimeout)
+	defer cancel()
 
-	var tok oauth2.Token
-	if err := json.NewDecoder(f).Decode(&tok); err != nil {
+	select {
+	case code := <-codes:
+		tok, err := flow.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+		if err != nil {
+			return nil, fmt.Errorf("cannot exchange code for token: %w", err)
+		}
+		return tok, nil
+	case err := <-errs:
 		return nil, err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for authorization: %w", ctx.Err())
 	}
-	return &tok, nil
 }
 
-func saveToken(path string, tok *oauth2.Token) {
-	f, err := os.Create(path)
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// persistingTokenSource writes tokens back to the store whenever the
+// underlying source refreshes or rotates them. A token that cannot be saved
+// is not handed out: a rotated refresh token that only lives in memory would
+// leave the account unauthorized after a restart. The save is retried on the
+// next call.
+type persistingTokenSource struct {
+	src   oauth2.TokenSource
+	store TokenStore
+
+	mu   sync.Mutex
+	last *oauth2.Token
+}
+
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.src.Token()
 	if err != nil {
-		log.Printf("cannot save token: %v", err)
-		return
+		return nil, err
 	}
-	defer f.Close()
 
-	_ = json.NewEncoder(f).Encode(tok)
-	log.Printf("token saved to %s\n", path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil || tok.AccessToken != s.last.AccessToken || tok.RefreshToken != s.last.RefreshToken {
+		if err := s.store.Save(tok); err != nil {
+			return nil, emailapp.Transient(fmt.Errorf("persist refreshed token: %w", err), 0)
+		}
+		s.last = tok
+	}
+
+	return tok, nil
 }
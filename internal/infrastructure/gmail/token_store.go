@@ -0,0 +1,151 @@
+package gmail
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+)
+
+// encryptedPrefix marks token files sealed with AES-256-GCM
+const encryptedPrefix = "mailassist-token:v1:"
+
+// TokenStore persists one account's OAuth token. Load returns an error
+// matching os.ErrNotExist when no token is stored yet.
+type TokenStore interface {
+	Load() (*oauth2.Token, error)
+	Save(tok *oauth2.Token) error
+}
+
+// FileTokenStore keeps a token in a file readable only by the owner. With a
+// key the token is encrypted at rest; a plaintext file from an older version
+// is encrypted when it is loaded.
+type FileTokenStore struct {
+	path string
+	aead cipher.AEAD
+}
+
+// NewFileTokenStore stores the token at path, encrypted when key (32 bytes)
+// is set
+func NewFileTokenStore(path string, key []byte) (*FileTokenStore, error) {
+	s := &FileTokenStore{path: path}
+	if key == nil {
+		return s, nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("token encryption key: %w", err)
+	}
+	s.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("token encryption key: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *FileTokenStore) Load() (*oauth2.Token, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := bytes.HasPrefix(data, []byte(encryptedPrefix))
+	switch {
+	case encrypted && s.aead == nil:
+		return nil, fmt.Errorf("%s is encrypted but no token encryption key is configured", s.path)
+	case encrypted:
+		if data, err = s.open(data); err != nil {
+			return nil, err
+		}
+	case s.aead != nil:
+		log.Printf("Encrypting plaintext token %s", s.path)
+	}
+
+	var tok oauth2.Token
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", s.path, err)
+	}
+	if s.aead != nil && !encrypted {
+		if err := s.Save(&tok); err != nil {
+			return nil, err
+		}
+	}
+
+	return &tok, nil
+}
+
+// Save writes the token atomically with 0600 permissions
+func (s *FileTokenStore) Save(tok *oauth2.Token) error {
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return fmt.Errorf("encode token: %w", err)
+	}
+	if s.aead != nil {
+		if data, err = s.seal(data); err != nil {
+			return err
+		}
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create %s: %w", dir, err)
+	}
+
+	f, err := os.CreateTemp(dir, ".token-*")
+	if err != nil {
+		return fmt.Errorf("save token: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("save token: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("save token: %w", err)
+	}
+	// CreateTemp already uses 0600, but be explicit about the contract
+	if err := os.Chmod(f.Name(), 0o600); err != nil {
+		return fmt.Errorf("save token: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return fmt.Errorf("save token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileTokenStore) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, plaintext, nil)
+	return []byte(encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func (s *FileTokenStore) open(data []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data[len(encryptedPrefix):])))
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return nil, fmt.Errorf("%s is corrupt", s.path)
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: wrong key or corrupt file", s.path)
+	}
+
+	return plaintext, nil
+}
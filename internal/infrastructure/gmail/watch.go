@@ -0,0 +1,32 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// EnableWatch enables Gmail push notifications and returns when they expire
+func (c *Client) EnableWatch(ctx context.Context, topicName string) (time.Time, error) {
+	req := &gmail.WatchRequest{
+		TopicName: topicName,
+	}
+
+	resp, err := c.Srv.Users.Watch("me", req).Context(ctx).Do()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("gmail watch: %w", err)
+	}
+
+	return time.UnixMilli(resp.Expiration), nil
+}
+
+// StopWatch stops Gmail push notifications for the mailbox
+func (c *Client) StopWatch(ctx context.Context) error {
+	if err := c.Srv.Users.Stop("me").Context(ctx).Do(); err != nil {
+		return fmt.Errorf("gmail stop: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// NewHTTPClient returns an HTTP client authorized as the Azure AD app itself.
+// The app needs the Mail.ReadWrite and MailboxSettings.ReadWrite application
+// permissions for every mailbox it serves.
+func NewHTTPClient(ctx context.Context, tenantID, clientID, clientSecret string) *http.Client {
+	cfg := clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID),
+		Scopes:       []string{"https://graph.microsoft.com/.default"},
+	}
+
+	client := cfg.Client(ctx)
+	client.Timeout = time.Minute
+	return client
+}
@@ -0,0 +1,203 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+
+	"mailassist/internal/domain/email"
+	"mailassist/internal/infrastructure/mailtext"
+)
+
+// BaseURL is the Graph v1.0 endpoint
+const BaseURL = "https://graph.microsoft.com/v1.0"
+
+// Client implements the mailbox ports on top of Microsoft Graph for one
+// Outlook / Exchange Online mailbox (adapter)
+type Client struct {
+	http    *http.Client
+	baseURL string
+	user    string
+	deltas  DeltaStore
+}
+
+func NewClient(httpClient *http.Client, baseURL, user string, deltas DeltaStore) *Client {
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+
+	return &Client{
+		http:    httpClient,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		user:    user,
+		deltas:  deltas,
+	}
+}
+
+// categoryNames maps domain labels to Outlook categories
+var categoryNames = map[email.Label]string{
+	email.LabelNewsletter:   "Newsletter",
+	email.LabelPrivate:      "Private",
+	email.LabelBusiness:     "Business",
+	email.LabelPayments:     "Payments",
+	email.LabelActionNeeded: "Action Needed",
+	email.LabelJunk:         "Junk",
+}
+
+type category struct {
+	DisplayName string `json:"displayName"`
+	Color       string `json:"color,omitempty"`
+}
+
+type emailAddress struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type recipient struct {
+	EmailAddress emailAddress `json:"emailAddress"`
+}
+
+type message struct {
+	ID                string     `json:"id"`
+	ConversationID    string     `json:"conversationId"`
+	InternetMessageID string     `json:"internetMessageId"`
+	Subject           string     `json:"subject"`
+	From              *recipient `json:"from"`
+	Body              *struct {
+		ContentType string `json:"contentType"`
+		Content     string `json:"content"`
+	} `json:"body"`
+	InternetMessageHeaders []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"internetMessageHeaders"`
+	Categories []string `json:"categories"`
+}
+
+// InitLabels makes sure the mailbox's master category list has a category
+// for every label
+func (c *Client) InitLabels(ctx context.Context) error {
+	var list struct {
+		Value []category `json:"value"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.userPath("/outlook/masterCategories"), nil, nil, &list); err != nil {
+		return fmt.Errorf("list categories: %w", err)
+	}
+
+	existing := make(map[string]bool, len(list.Value))
+	for _, cat := range list.Value {
+		existing[cat.DisplayName] = true
+	}
+
+	for _, name := range categoryNames {
+		if existing[name] {
+			continue
+		}
+		req := category{DisplayName: name, Color: "preset0"}
+		if err := c.do(ctx, http.MethodPost, c.userPath("/outlook/masterCategories"), nil, req, nil); err != nil {
+			return fmt.Errorf("create category %q: %w", name, err)
+		}
+		log.Printf("Created Outlook category %q for %s", name, c.user)
+	}
+
+	return nil
+}
+
+func (c *Client) FetchEmail(ctx context.Context, messageID string) (*email.Email, error) {
+	query := url.Values{}
+	query.Set("$select", "id,conversationId,internetMessageId,subject,from,body,internetMessageHeaders")
+
+	// Ask for a plain text body; Graph converts HTML itself
+	headers := map[string]string{"Prefer": `outlook.body-content-type="text"`}
+
+	var msg message
+	if err := c.do(ctx, http.MethodGet, c.messagePath(messageID)+"?"+encodeQuery(query), headers, nil, &msg); err != nil {
+		return nil, fmt.Errorf("graph get message: %w", err)
+	}
+
+	var body string
+	if msg.Body != nil {
+		body = strings.TrimSpace(msg.Body.Content)
+		if strings.EqualFold(msg.Body.ContentType, "html") {
+			body = mailtext.HTMLToText(body)
+		}
+	}
+
+	e := email.NewEmail(messageID, formatAddress(msg.From), msg.Subject, body)
+	e.Headers = make(map[string]string, len(msg.InternetMessageHeaders))
+	for _, h := range msg.InternetMessageHeaders {
+		key := textproto.CanonicalMIMEHeaderKey(h.Name)
+		if _, ok := e.Headers[key]; !ok {
+			e.Headers[key] = h.Value
+		}
+	}
+	e.ThreadID = msg.ConversationID
+	e.MessageID = msg.InternetMessageID
+	e.References = e.Header("References")
+
+	return e, nil
+}
+
+// ApplyLabel adds the label's category; Graph replaces the whole category
+// list, so the current one is read first
+func (c *Client) ApplyLabel(ctx context.Context, messageID string, label email.Label) error {
+	name, ok := categoryNames[label]
+	if !ok {
+		return fmt.Errorf("no Outlook category for label %q", label)
+	}
+
+	var msg message
+	if err := c.do(ctx, http.MethodGet, c.messagePath(messageID)+"?$select=categories", nil, nil, &msg); err != nil {
+		return fmt.Errorf("graph get categories: %w", err)
+	}
+	if slices.Contains(msg.Categories, name) {
+		return nil
+	}
+
+	req := map[string][]string{"categories": append(msg.Categories, name)}
+	if err := c.do(ctx, http.MethodPatch, c.messagePath(messageID), nil, req, nil); err != nil {
+		return fmt.Errorf("graph update categories: %w", err)
+	}
+
+	return nil
+}
+
+// CreateDraft creates a reply draft with createReply, which keeps the
+// conversation and quotes the original
+func (c *Client) CreateDraft(ctx context.Context, original *email.Email, body string) error {
+	req := map[string]string{"comment": body}
+	if err := c.do(ctx, http.MethodPost, c.messagePath(original.GmailID)+"/createReply", nil, req, nil); err != nil {
+		return fmt.Errorf("graph create reply: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) userPath(suffix string) string {
+	return "/users/" + url.PathEscape(c.user) + suffix
+}
+
+func (c *Client) messagePath(messageID string) string {
+	return c.userPath("/messages/" + url.PathEscape(messageID))
+}
+
+// encodeQuery encodes spaces as %20, which OData filters expect
+func encodeQuery(v url.Values) string {
+	return strings.ReplaceAll(v.Encode(), "+", "%20")
+}
+
+func formatAddress(from *recipient) string {
+	if from == nil {
+		return ""
+	}
+	if from.EmailAddress.Name == "" || from.EmailAddress.Name == from.EmailAddress.Address {
+		return from.EmailAddress.Address
+	}
+	return fmt.Sprintf("%s <%s>", from.EmailAddress.Name, from.EmailAddress.Address)
+}
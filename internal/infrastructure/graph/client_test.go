@@ -0,0 +1,256 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"mailassist/internal/domain/email"
+)
+
+const testUser = "user@contoso.com"
+
+// fakeGraph serves the Graph endpoints the adapter uses for one mailbox
+type fakeGraph struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	categories []string
+	messages   map[string][]string
+	requests   []string
+	bodies     map[string]string
+	// handle, when set, answers requests the fake does not know
+	handle http.HandlerFunc
+}
+
+func newFakeGraph(t *testing.T) *fakeGraph {
+	t.Helper()
+	g := &fakeGraph{t: t, messages: make(map[string][]string), bodies: make(map[string]string)}
+	g.srv = httptest.NewServer(http.HandlerFunc(g.serve))
+	t.Cleanup(g.srv.Close)
+	return g
+}
+
+func (g *fakeGraph) client() *Client {
+	return NewClient(g.srv.Client(), g.srv.URL, testUser, &memoryDeltaStore{})
+}
+
+func (g *fakeGraph) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	call := r.Method + " " + r.URL.Path
+	g.requests = append(g.requests, call)
+	g.bodies[call] = string(body)
+
+	user := "/users/" + testUser
+	switch {
+	case call == "GET "+user+"/outlook/masterCategories":
+		var list []category
+		for _, name := range g.categories {
+			list = append(list, category{DisplayName: name})
+		}
+		writeJSON(w, map[string]any{"value": list})
+
+	case call == "POST "+user+"/outlook/masterCategories":
+		var c category
+		if err := json.Unmarshal(body, &c); err != nil {
+			g.t.Errorf("decode category: %v", err)
+		}
+		g.categories = append(g.categories, c.DisplayName)
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, c)
+
+	case isCategoryCall(r, user):
+		id := strings.TrimPrefix(r.URL.Path, user+"/messages/")
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, map[string]any{"id": id, "categories": g.messages[id]})
+		case http.MethodPatch:
+			var req struct {
+				Categories []string `json:"categories"`
+			}
+			if err := json.Unmarshal(body, &req); err != nil {
+				g.t.Errorf("decode patch: %v", err)
+			}
+			g.messages[id] = req.Categories
+			writeJSON(w, map[string]any{"id": id, "categories": req.Categories})
+		}
+
+	default:
+		if g.handle != nil {
+			g.handle(w, r)
+			return
+		}
+		g.t.Errorf("unexpected request %s", call)
+		http.NotFound(w, r)
+	}
+}
+
+// isCategoryCall reports whether r reads or updates a message's categories
+func isCategoryCall(r *http.Request, user string) bool {
+	id, ok := strings.CutPrefix(r.URL.Path, user+"/messages/")
+	if !ok || strings.Contains(id, "/") {
+		return false
+	}
+	return r.Method == http.MethodPatch || r.URL.Query().Get("$select") == "categories"
+}
+
+func (g *fakeGraph) count(call string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := 0
+	for _, r := range g.requests {
+		if r == call {
+			n++
+		}
+	}
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+type memoryDeltaStore struct {
+	link string
+}
+
+func (s *memoryDeltaStore) LoadDeltaLink(context.Context) (string, error) { return s.link, nil }
+
+func (s *memoryDeltaStore) SaveDeltaLink(_ context.Context, link string) error {
+	s.link = link
+	return nil
+}
+
+func TestInitLabelsCreatesMissingCategories(t *testing.T) {
+	g := newFakeGraph(t)
+	g.categories = []string{"Private", "Red category"}
+	c := g.client()
+
+	if err := c.InitLabels(context.Background()); err != nil {
+		t.Fatalf("InitLabels: %v", err)
+	}
+
+	var want []string
+	for _, name := range categoryNames {
+		want = append(want, name)
+	}
+	want = append(want, "Red category")
+	got := append([]string(nil), g.categories...)
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("categories = %v, want %v", got, want)
+	}
+	if n := g.count("POST /users/" + testUser + "/outlook/masterCategories"); n != len(categoryNames)-1 {
+		t.Errorf("created %d categories, want %d", n, len(categoryNames)-1)
+	}
+
+	// Everything exists now
+	if err := c.InitLabels(context.Background()); err != nil {
+		t.Fatalf("InitLabels again: %v", err)
+	}
+	if n := g.count("POST /users/" + testUser + "/outlook/masterCategories"); n != len(categoryNames)-1 {
+		t.Errorf("second run created categories, %d POSTs in total", n)
+	}
+}
+
+func TestApplyLabelKeepsExistingCategories(t *testing.T) {
+	g := newFakeGraph(t)
+	g.messages["AAMk=1"] = []string{"Blue category"}
+	c := g.client()
+
+	if err := c.ApplyLabel(context.Background(), "AAMk=1", email.LabelPayments); err != nil {
+		t.Fatalf("ApplyLabel: %v", err)
+	}
+
+	if want := []string{"Blue category", "Payments"}; !reflect.DeepEqual(g.messages["AAMk=1"], want) {
+		t.Errorf("categories = %v, want %v", g.messages["AAMk=1"], want)
+	}
+}
+
+func TestApplyLabelSkipsPresentCategory(t *testing.T) {
+	g := newFakeGraph(t)
+	g.messages["AAMk=2"] = []string{"Payments"}
+	c := g.client()
+
+	if err := c.ApplyLabel(context.Background(), "AAMk=2", email.LabelPayments); err != nil {
+		t.Fatalf("ApplyLabel: %v", err)
+	}
+	if n := g.count("PATCH /users/" + testUser + "/messages/AAMk=2"); n != 0 {
+		t.Errorf("sent %d PATCH requests for a category already present", n)
+	}
+}
+
+func TestCreateDraftUsesCreateReply(t *testing.T) {
+	g := newFakeGraph(t)
+	call := "POST /users/" + testUser + "/messages/AAMk=3/createReply"
+	g.handle = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method+" "+r.URL.Path != call {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]any{"id": "draft-1"})
+	}
+	c := g.client()
+
+	original := email.NewEmail("AAMk=3", "Alice <alice@contoso.com>", "Meeting", "Can we meet?")
+	if err := c.CreateDraft(context.Background(), original, "Tuesday works."); err != nil {
+		t.Fatalf("CreateDraft: %v", err)
+	}
+
+	var req map[string]string
+	if err := json.Unmarshal([]byte(g.bodies[call]), &req); err != nil {
+		t.Fatalf("decode createReply body %q: %v", g.bodies[call], err)
+	}
+	if req["comment"] != "Tuesday works." {
+		t.Errorf("createReply body = %v", req)
+	}
+}
+
+func TestFetchEmailAsksForTextBody(t *testing.T) {
+	g := newFakeGraph(t)
+	g.handle = func(w http.ResponseWriter, r *http.Request) {
+		if pref := r.Header.Get("Prefer"); pref != `outlook.body-content-type="text"` {
+			t.Errorf("Prefer = %q", pref)
+		}
+		writeJSON(w, map[string]any{
+			"id":                "AAMk=4",
+			"conversationId":    "conv-1",
+			"internetMessageId": "<m4@contoso.com>",
+			"subject":           "Invoice",
+			"from":              map[string]any{"emailAddress": map[string]string{"name": "Billing", "address": "billing@contoso.com"}},
+			"body":              map[string]string{"contentType": "text", "content": "  Please pay.  "},
+			"internetMessageHeaders": []map[string]string{
+				{"name": "references", "value": "<m3@contoso.com>"},
+			},
+		})
+	}
+	c := g.client()
+
+	e, err := c.FetchEmail(context.Background(), "AAMk=4")
+	if err != nil {
+		t.Fatalf("FetchEmail: %v", err)
+	}
+	if e.From != "Billing <billing@contoso.com>" || e.Subject != "Invoice" || e.Body != "Please pay." {
+		t.Errorf("email = %+v", e)
+	}
+	if e.ThreadID != "conv-1" || e.MessageID != "<m4@contoso.com>" || e.References != "<m3@contoso.com>" {
+		t.Errorf("threading = %q %q %q", e.ThreadID, e.MessageID, e.References)
+	}
+}
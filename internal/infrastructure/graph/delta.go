@@ -0,0 +1,170 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	emailapp "mailassist/internal/application/email"
+)
+
+// maxPageSize is the page size used when listing the inbox
+const maxPageSize = 500
+
+// baselineWindow is how far back the first delta round looks. Everything
+// older is covered by the inbox listing of the full resync.
+const baselineWindow = time.Minute
+
+// DeltaStore persists the mailbox's delta link between runs. Graph tracks
+// changes with opaque delta links instead of numbered history; the history ID
+// handed to the sync use case is the time the link was taken, so the
+// checkpoint still moves forward.
+type DeltaStore interface {
+	LoadDeltaLink(ctx context.Context) (string, error)
+	SaveDeltaLink(ctx context.Context, link string) error
+}
+
+type deltaPage struct {
+	Value []struct {
+		ID      string          `json:"id"`
+		Removed json.RawMessage `json:"@removed"`
+	} `json:"value"`
+	NextLink  string `json:"@odata.nextLink"`
+	DeltaLink string `json:"@odata.deltaLink"`
+}
+
+// CurrentHistoryID starts a new delta baseline for the inbox
+func (c *Client) CurrentHistoryID(ctx context.Context) (uint64, error) {
+	query := url.Values{}
+	query.Set("$select", "id")
+	query.Set("$filter", "receivedDateTime ge "+time.Now().Add(-baselineWindow).UTC().Format(time.RFC3339))
+
+	link, err := c.runDelta(ctx, c.userPath("/mailFolders/inbox/messages/delta")+"?"+encodeQuery(query), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.deltas.SaveDeltaLink(ctx, link); err != nil {
+		return 0, fmt.Errorf("save delta link: %w", err)
+	}
+
+	return uint64(time.Now().UnixMilli()), nil
+}
+
+// StreamNewMessagesSince calls fn for every inbox message added or changed
+// since the stored delta link. Changes include our own category updates;
+// the classification use case skips messages it already processed.
+func (c *Client) StreamNewMessagesSince(ctx context.Context, historyID uint64, fn func(messageID string) error) (uint64, error) {
+	link, err := c.deltas.LoadDeltaLink(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("load delta link: %w", err)
+	}
+	if link == "" {
+		return 0, fmt.Errorf("no delta link for %s: %w", c.user, emailapp.ErrHistoryExpired)
+	}
+
+	next, err := c.runDelta(ctx, link, fn)
+	if isSyncStateExpired(err) {
+		return 0, fmt.Errorf("graph delta: %w", emailapp.ErrHistoryExpired)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.deltas.SaveDeltaLink(ctx, next); err != nil {
+		return 0, fmt.Errorf("save delta link: %w", err)
+	}
+
+	return max(uint64(time.Now().UnixMilli()), historyID+1), nil
+}
+
+// StreamInboxMessages calls fn for up to maxResults inbox message IDs, newest
+// first. A maxResults of 0 or less lists the whole inbox.
+func (c *Client) StreamInboxMessages(ctx context.Context, maxResults int64, fn func(messageID string) error) error {
+	pageSize := int64(maxPageSize)
+	if maxResults > 0 && maxResults < pageSize {
+		pageSize = maxResults
+	}
+
+	query := url.Values{}
+	query.Set("$select", "id")
+	query.Set("$orderby", "receivedDateTime desc")
+	query.Set("$top", strconv.FormatInt(pageSize, 10))
+	target := c.userPath("/mailFolders/inbox/messages") + "?" + encodeQuery(query)
+
+	var seen int64
+	for target != "" {
+		var page deltaPage
+		if err := c.do(ctx, http.MethodGet, target, nil, nil, &page); err != nil {
+			return fmt.Errorf("list messages: %w", err)
+		}
+
+		for _, msg := range page.Value {
+			if maxResults > 0 && seen >= maxResults {
+				return nil
+			}
+			if err := fn(msg.ID); err != nil {
+				return err
+			}
+			seen++
+		}
+
+		if maxResults > 0 && seen >= maxResults {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		target = page.NextLink
+	}
+
+	return nil
+}
+
+// runDelta follows a delta round to its end, calling fn (when set) for every
+// message that was not removed, and returns the next delta link
+func (c *Client) runDelta(ctx context.Context, target string, fn func(messageID string) error) (string, error) {
+	for {
+		var page deltaPage
+		if err := c.do(ctx, http.MethodGet, target, nil, nil, &page); err != nil {
+			return "", fmt.Errorf("graph delta: %w", err)
+		}
+
+		if fn != nil {
+			for _, msg := range page.Value {
+				if len(msg.Removed) > 0 {
+					continue
+				}
+				if err := fn(msg.ID); err != nil {
+					return "", err
+				}
+			}
+		}
+
+		switch {
+		case page.DeltaLink != "":
+			return page.DeltaLink, nil
+		case page.NextLink != "":
+			target = page.NextLink
+		default:
+			return "", fmt.Errorf("graph delta: response has neither next nor delta link")
+		}
+	}
+}
+
+// isSyncStateExpired reports whether Graph no longer knows the delta link
+func isSyncStateExpired(err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Status == http.StatusGone ||
+		strings.EqualFold(apiErr.Code, "SyncStateNotFound") ||
+		strings.EqualFold(apiErr.Code, "SyncStateInvalid")
+}
@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	emailapp "mailassist/internal/application/email"
+)
+
+func TestStreamNewMessagesSinceFollowsDeltaPages(t *testing.T) {
+	g := newFakeGraph(t)
+	g.handle = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/delta/1":
+			writeJSON(w, map[string]any{
+				"value": []map[string]any{
+					{"id": "m1"},
+					{"id": "m2", "@removed": map[string]string{"reason": "deleted"}},
+				},
+				"@odata.nextLink": g.srv.URL + "/delta/2",
+			})
+		case "/delta/2":
+			writeJSON(w, map[string]any{
+				"value":            []map[string]any{{"id": "m3"}},
+				"@odata.deltaLink": g.srv.URL + "/delta/3",
+			})
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}
+	deltas := &memoryDeltaStore{link: g.srv.URL + "/delta/1"}
+	c := NewClient(g.srv.Client(), g.srv.URL, testUser, deltas)
+
+	var ids []string
+	latest, err := c.StreamNewMessagesSince(context.Background(), 42, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamNewMessagesSince: %v", err)
+	}
+
+	if want := []string{"m1", "m3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if deltas.link != g.srv.URL+"/delta/3" {
+		t.Errorf("saved delta link %q, want the last page's", deltas.link)
+	}
+	if latest <= 42 {
+		t.Errorf("checkpoint %d did not move past 42", latest)
+	}
+}
+
+func TestStreamNewMessagesSinceWithoutDeltaLink(t *testing.T) {
+	g := newFakeGraph(t)
+	c := g.client()
+
+	_, err := c.StreamNewMessagesSince(context.Background(), 1, func(string) error { return nil })
+	if !errors.Is(err, emailapp.ErrHistoryExpired) {
+		t.Fatalf("err = %v, want ErrHistoryExpired", err)
+	}
+}
+
+func TestStreamNewMessagesSinceExpiredSyncState(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"gone", http.StatusGone, `{"error":{"code":"ResyncRequired","message":"resync"}}`},
+		{"sync state not found", http.StatusBadRequest, `{"error":{"code":"SyncStateNotFound","message":"not found"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGraph(t)
+			g.handle = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}
+			deltas := &memoryDeltaStore{link: g.srv.URL + "/delta/stale"}
+			c := NewClient(g.srv.Client(), g.srv.URL, testUser, deltas)
+
+			_, err := c.StreamNewMessagesSince(context.Background(), 1, func(string) error {
+				t.Error("callback called for an expired delta link")
+				return nil
+			})
+			if !errors.Is(err, emailapp.ErrHistoryExpired) {
+				t.Fatalf("err = %v, want ErrHistoryExpired", err)
+			}
+			if deltas.link != g.srv.URL+"/delta/stale" {
+				t.Errorf("delta link replaced with %q", deltas.link)
+			}
+		})
+	}
+}
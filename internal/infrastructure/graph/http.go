@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"mailassist/internal/infrastructure/upstream"
+)
+
+// apiError is an error answer from Graph
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("graph status %d: %s: %s", e.Status, e.Code, e.Message)
+}
+
+// do sends req as JSON (when set) and decodes a 2xx response into resp (when
+// set). target is a path below the base URL or an absolute paging link.
+func (c *Client) do(ctx context.Context, method, target string, headers map[string]string, req, resp any) error {
+	var payload io.Reader
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		payload = bytes.NewReader(b)
+	}
+
+	url := target
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		url = c.baseURL + target
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return upstream.Classify(err, 0, nil)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, 10<<20))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	if httpResp.StatusCode/100 != 2 {
+		apiErr := &apiError{Status: httpResp.StatusCode, Message: string(bytes.TrimSpace(body))}
+		var errResp struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Code != "" {
+			apiErr.Code = errResp.Error.Code
+			apiErr.Message = errResp.Error.Message
+		}
+		return upstream.Classify(apiErr, httpResp.StatusCode, httpResp.Header)
+	}
+
+	if resp == nil || len(body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	emailapp "mailassist/internal/application/email"
+)
+
+func TestDoClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		transient  bool
+		wait       time.Duration
+	}{
+		{"throttled", http.StatusTooManyRequests, "7", true, 7 * time.Second},
+		{"unavailable", http.StatusServiceUnavailable, "", true, 0},
+		{"not found", http.StatusNotFound, "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGraph(t)
+			g.handle = func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"error":{"code":"SomeCode","message":"went wrong"}}`))
+			}
+			c := g.client()
+
+			err := c.do(context.Background(), http.MethodGet, "/anything", nil, nil, nil)
+			if err == nil {
+				t.Fatal("do succeeded on an error status")
+			}
+
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want an apiError", err)
+			}
+			if apiErr.Status != tt.status || apiErr.Code != "SomeCode" || apiErr.Message != "went wrong" {
+				t.Errorf("apiError = %+v", apiErr)
+			}
+			if got := emailapp.IsTransient(err); got != tt.transient {
+				t.Errorf("IsTransient = %v, want %v", got, tt.transient)
+			}
+			if got := emailapp.RetryAfter(err); got != tt.wait {
+				t.Errorf("RetryAfter = %v, want %v", got, tt.wait)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package imap
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"mailassist/internal/domain/email"
+	"mailassist/internal/infrastructure/mailtext"
+)
+
+const (
+	// LabelModeKeyword marks messages with an IMAP keyword per label
+	LabelModeKeyword = "keyword"
+	// LabelModeFolder moves messages into a folder per label
+	LabelModeFolder = "folder"
+)
+
+type Config struct {
+	// Addr is the server's host:port
+	Addr     string
+	Username string
+	Password string
+	// TLS dials with implicit TLS; otherwise STARTTLS is used when offered
+	TLS bool
+
+	// Mailbox is the folder watched for new mail
+	Mailbox string
+	// DraftsMailbox receives reply drafts
+	DraftsMailbox string
+	LabelMode     string
+
+	// Timeout bounds every IMAP command
+	Timeout time.Duration
+}
+
+// Client implements the mailbox ports on top of IMAP (adapter). IMAP
+// connections are not safe for concurrent commands, so calls are serialized
+// on one connection that is redialed after a failure.
+type Client struct {
+	cfg Config
+
+	mu   sync.Mutex
+	conn *client.Client
+}
+
+// labelNames maps domain labels to folder names; keywords use the same names
+// without spaces
+var labelNames = map[email.Label]string{
+	email.LabelNewsletter:   "Newsletter",
+	email.LabelPrivate:      "Private",
+	email.LabelBusiness:     "Business",
+	email.LabelPayments:     "Payments",
+	email.LabelActionNeeded: "Action Needed",
+	email.LabelJunk:         "Junk",
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.Mailbox == "" {
+		cfg.Mailbox = "INBOX"
+	}
+	if cfg.DraftsMailbox == "" {
+		cfg.DraftsMailbox = "Drafts"
+	}
+	if cfg.LabelMode == "" {
+		cfg.LabelMode = LabelModeKeyword
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Minute
+	}
+
+	return &Client{cfg: cfg}
+}
+
+// InitLabels creates the label folders in folder mode
+func (c *Client) InitLabels() error {
+	if c.cfg.LabelMode != LabelModeFolder {
+		return nil
+	}
+
+	return c.withConn(context.Background(), func(conn *client.Client) error {
+		existing := make(map[string]bool)
+		mailboxes := make(chan *imap.MailboxInfo, 16)
+		done := make(chan error, 1)
+		go func() {
+			done <- conn.List("", "*", mailboxes)
+		}()
+		for m := range mailboxes {
+			existing[m.Name] = true
+		}
+		if err := <-done; err != nil {
+			return fmt.Errorf("list mailboxes: %w", err)
+		}
+
+		for _, name := range labelNames {
+			if existing[name] {
+				continue
+			}
+			if err := conn.Create(name); err != nil {
+				return fmt.Errorf("create mailbox %q: %w", name, err)
+			}
+			log.Printf("Created IMAP folder %q", name)
+		}
+
+		return nil
+	})
+}
+
+func (c *Client) FetchEmail(ctx context.Context, messageID string) (*email.Email, error) {
+	validity, uid, err := parseMessageID(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var e *email.Email
+	err = c.withConn(ctx, func(conn *client.Client) error {
+		if err := checkValidity(conn, validity, messageID); err != nil {
+			return err
+		}
+
+		section := &imap.BodySectionName{Peek: true}
+		messages := make(chan *imap.Message, 1)
+		done := make(chan error, 1)
+		go func() {
+			done <- conn.UidFetch(uidSet(uid), []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages)
+		}()
+
+		var msg *imap.Message
+		for m := range messages {
+			msg = m
+		}
+		if err := <-done; err != nil {
+			return fmt.Errorf("imap fetch: %w", err)
+		}
+		if msg == nil {
+			return fmt.Errorf("message %s not found", messageID)
+		}
+
+		body := msg.GetBody(section)
+		if body == nil {
+			return fmt.Errorf("message %s has no body", messageID)
+		}
+
+		e, err = parseMessage(messageID, body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// ApplyLabel sets the label's keyword or, in folder mode, moves the message
+// out of the watched mailbox into the label's folder
+func (c *Client) ApplyLabel(ctx context.Context, messageID string, label email.Label) error {
+	name, ok := labelNames[label]
+	if !ok {
+		return fmt.Errorf("no IMAP name for label %q", label)
+	}
+
+	validity, uid, err := parseMessageID(messageID)
+	if err != nil {
+		return err
+	}
+
+	return c.withConn(ctx, func(conn *client.Client) error {
+		if err := checkValidity(conn, validity, messageID); err != nil {
+			return err
+		}
+
+		// Servers without MOVE get COPY, \Deleted and EXPUNGE instead, which
+		// also expunges other messages already flagged \Deleted
+		if c.cfg.LabelMode == LabelModeFolder {
+			if err := conn.UidMove(uidSet(uid), name); err != nil {
+				return fmt.Errorf("imap move to %q: %w", name, err)
+			}
+			return nil
+		}
+
+		keyword := strings.ReplaceAll(name, " ", "")
+		item := imap.FormatFlagsOp(imap.AddFlags, true)
+		if err := conn.UidStore(uidSet(uid), item, []interface{}{keyword}, nil); err != nil {
+			return fmt.Errorf("imap store %q: %w", keyword, err)
+		}
+		return nil
+	})
+}
+
+// CreateDraft appends a reply to the drafts folder. IMAP has no thread IDs;
+// the reply headers keep the draft in the conversation.
+func (c *Client) CreateDraft(ctx context.Context, original *email.Email, body string) error {
+	raw := mailtext.BuildReply(c.cfg.Username, original, body)
+
+	return c.withConn(ctx, func(conn *client.Client) error {
+		flags := []string{imap.DraftFlag, imap.SeenFlag}
+		if err := conn.Append(c.cfg.DraftsMailbox, flags, time.Now(), bytes.NewBufferString(raw)); err != nil {
+			return fmt.Errorf("imap append to %q: %w", c.cfg.DraftsMailbox, err)
+		}
+		return nil
+	})
+}
+
+// Close logs out of the command connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Logout()
+	c.conn = nil
+	return err
+}
+
+// withConn runs fn on the shared connection, dialing it first if needed. A
+// connection that failed is dropped so the next call starts fresh.
+func (c *Client) withConn(ctx context.Context, fn func(conn *client.Client) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		conn, err := c.dial(nil, false)
+		if err != nil {
+			return classifyError(err)
+		}
+		c.conn = conn
+	}
+
+	err := fn(c.conn)
+	if err != nil && isConnError(err) {
+		_ = c.conn.Terminate()
+		c.conn = nil
+	}
+
+	return classifyError(err)
+}
+
+// dial connects, logs in and selects the watched mailbox. updates receives
+// unilateral server updates, e.g. while idling.
+func (c *Client) dial(updates chan<- client.Update, readOnly bool) (*client.Client, error) {
+	host, _, err := net.SplitHostPort(c.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("imap address %q: %w", c.cfg.Addr, err)
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+
+	var conn *client.Client
+	if c.cfg.TLS {
+		conn, err = client.DialTLS(c.cfg.Addr, tlsConfig)
+	} else {
+		conn, err = client.Dial(c.cfg.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imap dial: %w", err)
+	}
+	conn.Timeout = c.cfg.Timeout
+	conn.Updates = updates
+
+	if !c.cfg.TLS {
+		if ok, _ := conn.SupportStartTLS(); ok {
+			if err := conn.StartTLS(tlsConfig); err != nil {
+				_ = conn.Terminate()
+				return nil, fmt.Errorf("imap starttls: %w", err)
+			}
+		}
+	}
+
+	if err := conn.Login(c.cfg.Username, c.cfg.Password); err != nil {
+		_ = conn.Terminate()
+		if !isConnError(err) {
+			return nil, fmt.Errorf("imap login: %w: %v", errLoginRejected, err)
+		}
+		return nil, fmt.Errorf("imap login: %w", err)
+	}
+
+	if _, err := conn.Select(c.cfg.Mailbox, readOnly); err != nil {
+		_ = conn.Logout()
+		return nil, fmt.Errorf("imap select %q: %w", c.cfg.Mailbox, err)
+	}
+
+	return conn, nil
+}
+
+// Message IDs are "<uidvalidity>:<uid>", so an ID never points to a
+// different message after the server renumbers the mailbox
+func formatMessageID(validity, uid uint32) string {
+	return fmt.Sprintf("%d:%d", validity, uid)
+}
+
+func parseMessageID(messageID string) (uint32, uint32, error) {
+	v, u, ok := strings.Cut(messageID, ":")
+	validity, err1 := strconv.ParseUint(v, 10, 32)
+	uid, err2 := strconv.ParseUint(u, 10, 32)
+	if !ok || err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("invalid IMAP message id %q", messageID)
+	}
+	return uint32(validity), uint32(uid), nil
+}
+
+func checkValidity(conn *client.Client, validity uint32, messageID string) error {
+	if status := conn.Mailbox(); status != nil && status.UidValidity != validity {
+		return fmt.Errorf("message %s no longer exists, mailbox UIDVALIDITY is now %d", messageID, status.UidValidity)
+	}
+	return nil
+}
+
+func uidSet(uid uint32) *imap.SeqSet {
+	set := new(imap.SeqSet)
+	set.AddNum(uid)
+	return set
+}
@@ -0,0 +1,189 @@
+package imap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap"
+	"mailassist/internal/domain/email"
+)
+
+const multipartLatin1 = `From: =?ISO-8859-1?Q?J=F6rg_M=FCller?= <joerg@example.com>
+To: username@example.com
+Subject: =?UTF-8?B?UmVjaG51bmcgZsO8ciBNw6Ryeg==?=
+Message-ID: <invoice-1@example.com>
+References: <thread-0@example.com>
+MIME-Version: 1.0
+Content-Type: multipart/alternative; boundary="b1"
+
+--b1
+Content-Type: text/plain; charset=ISO-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Gr=FC=DFe, die Rechnung ist f=E4llig.
+Link: https://example.com/pay?id=3DAB12&x=3D3
+--b1
+Content-Type: text/html; charset=UTF-8
+
+<p>HTML version</p>
+--b1--
+`
+
+const htmlOnlyBase64 = `From: shop@example.com
+Subject: Sale
+Content-Type: text/html; charset=UTF-8
+Content-Transfer-Encoding: base64
+
+PHA+SGFsbG8gPGI+V2VsdDwvYj4hPC9wPg==
+`
+
+func TestFetchEmailDecodesMIME(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+	uid := ts.deliver(t, multipartLatin1)
+
+	id := formatMessageID(memoryValidity, uid)
+	e, err := c.FetchEmail(context.Background(), id)
+	if err != nil {
+		t.Fatalf("FetchEmail: %v", err)
+	}
+
+	if e.GmailID != id {
+		t.Errorf("GmailID = %q, want %q", e.GmailID, id)
+	}
+	if e.Subject != "Rechnung für März" {
+		t.Errorf("Subject = %q", e.Subject)
+	}
+	if !strings.Contains(e.From, "Jörg Müller") {
+		t.Errorf("From = %q", e.From)
+	}
+	want := "Grüße, die Rechnung ist fällig.\r\nLink: https://example.com/pay?id=AB12&x=3"
+	if e.Body != want {
+		t.Errorf("Body = %q, want %q", e.Body, want)
+	}
+	if e.MessageID != "<invoice-1@example.com>" || e.References != "<thread-0@example.com>" {
+		t.Errorf("MessageID = %q, References = %q", e.MessageID, e.References)
+	}
+
+	// Fetching peeks, the message must stay unread
+	for _, flag := range ts.message(t, "INBOX", uid).Flags {
+		if flag == imap.SeenFlag {
+			t.Errorf("FetchEmail marked the message as seen")
+		}
+	}
+}
+
+func TestFetchEmailFallsBackToHTML(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+	uid := ts.deliver(t, htmlOnlyBase64)
+
+	e, err := c.FetchEmail(context.Background(), formatMessageID(memoryValidity, uid))
+	if err != nil {
+		t.Fatalf("FetchEmail: %v", err)
+	}
+	if e.Body != "Hallo Welt!" {
+		t.Errorf("Body = %q, want %q", e.Body, "Hallo Welt!")
+	}
+}
+
+func TestFetchEmailRejectsStaleUIDValidity(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+
+	if _, err := c.FetchEmail(context.Background(), formatMessageID(memoryValidity+1, seededUID)); err == nil {
+		t.Fatal("FetchEmail accepted an ID from another UIDVALIDITY")
+	}
+	if _, err := c.FetchEmail(context.Background(), "not-an-id"); err == nil {
+		t.Fatal("FetchEmail accepted a malformed ID")
+	}
+}
+
+func TestApplyLabelKeyword(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+
+	if err := c.ApplyLabel(context.Background(), formatMessageID(memoryValidity, seededUID), email.LabelActionNeeded); err != nil {
+		t.Fatalf("ApplyLabel: %v", err)
+	}
+
+	msg := ts.message(t, "INBOX", seededUID)
+	if msg == nil {
+		t.Fatal("message left INBOX in keyword mode")
+	}
+	if !hasFlag(msg.Flags, "ActionNeeded") {
+		t.Errorf("flags = %v, want the ActionNeeded keyword", msg.Flags)
+	}
+}
+
+func TestApplyLabelFolderMovesMessage(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeFolder)
+
+	if err := c.InitLabels(); err != nil {
+		t.Fatalf("InitLabels: %v", err)
+	}
+	for _, name := range labelNames {
+		if _, err := ts.user.GetMailbox(name); err != nil {
+			t.Errorf("InitLabels did not create %q", name)
+		}
+	}
+	// A second run finds the folders and creates nothing
+	if err := c.InitLabels(); err != nil {
+		t.Fatalf("InitLabels again: %v", err)
+	}
+
+	if err := c.ApplyLabel(context.Background(), formatMessageID(memoryValidity, seededUID), email.LabelPayments); err != nil {
+		t.Fatalf("ApplyLabel: %v", err)
+	}
+
+	if msg := ts.message(t, "INBOX", seededUID); msg != nil {
+		t.Errorf("message is still in INBOX with flags %v", msg.Flags)
+	}
+	moved := ts.mailbox(t, "Payments").Messages
+	if len(moved) != 1 || !strings.Contains(string(moved[0].Body), "A little message, just for you") {
+		t.Fatalf("Payments holds %d messages, want the labeled one", len(moved))
+	}
+	if hasFlag(moved[0].Flags, imap.DeletedFlag) {
+		t.Errorf("moved message is flagged deleted")
+	}
+}
+
+func TestCreateDraftAppendsReply(t *testing.T) {
+	ts := newTestServer(t, "Drafts")
+	c := ts.client(t, LabelModeKeyword)
+
+	original := email.NewEmail(formatMessageID(memoryValidity, seededUID), "Alice <alice@example.com>", "Meeting", "Can we meet?")
+	original.MessageID = "<meeting-1@example.com>"
+
+	if err := c.CreateDraft(context.Background(), original, "Tuesday works for me."); err != nil {
+		t.Fatalf("CreateDraft: %v", err)
+	}
+
+	drafts := ts.mailbox(t, "Drafts").Messages
+	if len(drafts) != 1 {
+		t.Fatalf("Drafts holds %d messages, want 1", len(drafts))
+	}
+	if !hasFlag(drafts[0].Flags, imap.DraftFlag) || !hasFlag(drafts[0].Flags, imap.SeenFlag) {
+		t.Errorf("draft flags = %v", drafts[0].Flags)
+	}
+
+	raw := string(drafts[0].Body)
+	for _, want := range []string{"alice@example.com", "In-Reply-To: <meeting-1@example.com>", "Tuesday works for me."} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("draft lacks %q:\n%s", want, raw)
+		}
+	}
+}
+
+// hasFlag compares case-insensitively like IMAP does; the server lowercases
+// keywords
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package imap
+
+import (
+	"errors"
+	"io"
+	"net"
+
+	"github.com/emersion/go-imap/client"
+	emailapp "mailassist/internal/application/email"
+)
+
+// errLoginRejected is returned when the server refuses the credentials;
+// retrying cannot help
+var errLoginRejected = errors.New("login rejected")
+
+// classifyError marks connection failures as transient; NO and BAD answers
+// from the server are not worth retrying
+func classifyError(err error) error {
+	if err == nil || !isConnError(err) {
+		return err
+	}
+	return emailapp.Transient(err, 0)
+}
+
+func isConnError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, client.ErrNotLoggedIn) || errors.Is(err, client.ErrAlreadyLoggedOut) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
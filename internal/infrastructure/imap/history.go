@@ -0,0 +1,107 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	emailapp "mailassist/internal/application/email"
+)
+
+// IMAP has no history log; UIDs only grow within one UIDVALIDITY. A history
+// ID packs UIDVALIDITY into the upper 32 bits and the last seen UID into the
+// lower ones, so a renumbered mailbox shows up as an expired checkpoint.
+func packHistoryID(validity, uid uint32) uint64 {
+	return uint64(validity)<<32 | uint64(uid)
+}
+
+func unpackHistoryID(historyID uint64) (uint32, uint32) {
+	return uint32(historyID >> 32), uint32(historyID)
+}
+
+// CurrentHistoryID returns the position of the newest message in the mailbox
+func (c *Client) CurrentHistoryID(ctx context.Context) (uint64, error) {
+	var historyID uint64
+
+	err := c.withConn(ctx, func(conn *client.Client) error {
+		status, err := conn.Status(c.cfg.Mailbox, []imap.StatusItem{imap.StatusUidNext, imap.StatusUidValidity})
+		if err != nil {
+			return fmt.Errorf("imap status: %w", err)
+		}
+
+		historyID = packHistoryID(status.UidValidity, status.UidNext-1)
+		return nil
+	})
+
+	return historyID, err
+}
+
+// StreamNewMessagesSince calls fn for every message with a UID above the
+// checkpoint and returns the new checkpoint
+func (c *Client) StreamNewMessagesSince(ctx context.Context, historyID uint64, fn func(messageID string) error) (uint64, error) {
+	validity, lastUID := unpackHistoryID(historyID)
+	latestID := historyID
+
+	err := c.withConn(ctx, func(conn *client.Client) error {
+		current := conn.Mailbox().UidValidity
+		if current != validity {
+			return fmt.Errorf("imap UIDVALIDITY changed from %d to %d: %w", validity, current, emailapp.ErrHistoryExpired)
+		}
+
+		criteria := imap.NewSearchCriteria()
+		criteria.Uid = new(imap.SeqSet)
+		criteria.Uid.AddRange(lastUID+1, 0)
+
+		uids, err := conn.UidSearch(criteria)
+		if err != nil {
+			return fmt.Errorf("imap search: %w", err)
+		}
+		sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+		for _, uid := range uids {
+			// "n:*" always matches the newest message, even below n
+			if uid <= lastUID {
+				continue
+			}
+			if err := fn(formatMessageID(validity, uid)); err != nil {
+				return err
+			}
+			latestID = packHistoryID(validity, uid)
+		}
+
+		return ctx.Err()
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return latestID, nil
+}
+
+// StreamInboxMessages calls fn for up to maxResults messages of the watched
+// mailbox, newest first. A maxResults of 0 or less lists the whole mailbox.
+func (c *Client) StreamInboxMessages(ctx context.Context, maxResults int64, fn func(messageID string) error) error {
+	return c.withConn(ctx, func(conn *client.Client) error {
+		validity := conn.Mailbox().UidValidity
+
+		uids, err := conn.UidSearch(imap.NewSearchCriteria())
+		if err != nil {
+			return fmt.Errorf("imap search: %w", err)
+		}
+		sort.Slice(uids, func(i, j int) bool { return uids[i] > uids[j] })
+
+		if maxResults > 0 && int64(len(uids)) > maxResults {
+			uids = uids[:maxResults]
+		}
+
+		for _, uid := range uids {
+			if err := fn(formatMessageID(validity, uid)); err != nil {
+				return err
+			}
+		}
+
+		return ctx.Err()
+	})
+}
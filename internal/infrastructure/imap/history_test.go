@@ -0,0 +1,116 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	emailapp "mailassist/internal/application/email"
+)
+
+func TestCurrentHistoryIDPointsAtNewestMessage(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+
+	id, err := c.CurrentHistoryID(context.Background())
+	if err != nil {
+		t.Fatalf("CurrentHistoryID: %v", err)
+	}
+	if want := packHistoryID(memoryValidity, seededUID); id != want {
+		t.Errorf("CurrentHistoryID = %d, want %d", id, want)
+	}
+}
+
+func TestStreamNewMessagesSinceFollowsUIDs(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+	ctx := context.Background()
+
+	checkpoint := packHistoryID(memoryValidity, seededUID)
+
+	// Nothing new: "n:*" still matches the newest message, which is skipped
+	ids, latest := streamAll(t, c, checkpoint)
+	if len(ids) != 0 || latest != checkpoint {
+		t.Fatalf("got %v and checkpoint %d, want nothing new", ids, latest)
+	}
+
+	uid1 := ts.deliver(t, "Subject: one\n\nfirst")
+	uid2 := ts.deliver(t, "Subject: two\n\nsecond")
+
+	ids, latest = streamAll(t, c, checkpoint)
+	want := []string{formatMessageID(memoryValidity, uid1), formatMessageID(memoryValidity, uid2)}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if latest != packHistoryID(memoryValidity, uid2) {
+		t.Errorf("checkpoint = %d, want UID %d", latest, uid2)
+	}
+
+	// Resuming from the new checkpoint yields nothing twice
+	ids, _ = streamAll(t, c, latest)
+	if len(ids) != 0 {
+		t.Errorf("ids after checkpoint = %v, want none", ids)
+	}
+
+	// A callback error stops the stream and is returned
+	stop := errors.New("stop")
+	_, err := c.StreamNewMessagesSince(ctx, checkpoint, func(string) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("err = %v, want the callback's error", err)
+	}
+}
+
+func TestStreamNewMessagesSinceExpiresOnUIDValidityChange(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+
+	_, err := c.StreamNewMessagesSince(context.Background(), packHistoryID(memoryValidity+1, seededUID), func(string) error {
+		t.Error("callback called for a stale checkpoint")
+		return nil
+	})
+	if !errors.Is(err, emailapp.ErrHistoryExpired) {
+		t.Fatalf("err = %v, want ErrHistoryExpired", err)
+	}
+}
+
+func TestStreamInboxMessagesNewestFirst(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+	uid := ts.deliver(t, "Subject: newer\n\nbody")
+
+	var ids []string
+	err := c.StreamInboxMessages(context.Background(), 0, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamInboxMessages: %v", err)
+	}
+	want := []string{formatMessageID(memoryValidity, uid), formatMessageID(memoryValidity, seededUID)}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+
+	ids = nil
+	err = c.StreamInboxMessages(context.Background(), 1, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil || len(ids) != 1 || ids[0] != want[0] {
+		t.Errorf("limited to 1: ids = %v, err = %v", ids, err)
+	}
+}
+
+func streamAll(t *testing.T, c *Client, checkpoint uint64) ([]string, uint64) {
+	t.Helper()
+	var ids []string
+	latest, err := c.StreamNewMessagesSince(context.Background(), checkpoint, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamNewMessagesSince: %v", err)
+	}
+	return ids, latest
+}
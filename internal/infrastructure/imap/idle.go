@@ -0,0 +1,111 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+const (
+	// idleRestart re-issues IDLE before servers drop idle clients (RFC 2177
+	// allows them to after 30 minutes)
+	idleRestart = 25 * time.Minute
+
+	maxReconnectDelay = 5 * time.Minute
+)
+
+// Listen watches the mailbox with IDLE on a dedicated connection and calls fn
+// whenever the mailbox changes, and once after every (re)connect to pick up
+// mail that arrived in between. It reconnects until ctx is cancelled and only
+// gives up, returning the error, when the server rejects the login.
+func (c *Client) Listen(ctx context.Context, fn func() error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+			if err := fn(); err != nil && ctx.Err() == nil {
+				log.Printf("IMAP sync for %s failed: %v", c.cfg.Username, err)
+			}
+		}
+	}()
+
+	delay := time.Second
+	for {
+		started := time.Now()
+		err := c.idle(ctx, changed)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, errLoginRejected) {
+			return err
+		}
+
+		if time.Since(started) > maxReconnectDelay {
+			delay = time.Second
+		}
+		log.Printf("IMAP IDLE for %s interrupted, reconnecting in %s: %v", c.cfg.Username, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+func (c *Client) idle(ctx context.Context, changed chan<- struct{}) error {
+	updates := make(chan client.Update, 16)
+
+	conn, err := c.dial(updates, true)
+	if err != nil {
+		return err
+	}
+	defer conn.Logout()
+
+	signal(changed)
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.Idle(stop, &client.IdleOptions{LogoutTimeout: idleRestart})
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			close(stop)
+			<-done
+			return nil
+		case update := <-updates:
+			if _, ok := update.(*client.MailboxUpdate); ok {
+				signal(changed)
+			}
+		case err := <-done:
+			if err == nil {
+				err = errors.New("idle ended")
+			}
+			return err
+		}
+	}
+}
+
+// signal records a pending change without blocking; one pending sync covers
+// any number of changes
+func signal(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,68 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListenWakesOnMailboxUpdate(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, LabelModeKeyword)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan struct{}, 4)
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- c.Listen(ctx, func() error {
+			calls <- struct{}{}
+			return nil
+		})
+	}()
+
+	// Every connect syncs once to pick up mail that arrived meanwhile
+	waitCall(t, calls, "initial sync")
+
+	ts.deliver(t, "Subject: new\n\nbody")
+	ts.notify(t)
+	waitCall(t, calls, "sync after mailbox update")
+
+	cancel()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("Listen returned %v after cancel, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen did not stop after cancel")
+	}
+}
+
+func TestListenGivesUpOnRejectedLogin(t *testing.T) {
+	ts := newTestServer(t)
+	c := NewClient(Config{Addr: ts.addr, Username: testUsername, Password: "wrong", Timeout: 5 * time.Second})
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- c.Listen(context.Background(), func() error { return nil })
+	}()
+
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, errLoginRejected) {
+			t.Errorf("Listen returned %v, want a rejected login", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen kept retrying a rejected login")
+	}
+}
+
+func waitCall(t *testing.T, calls <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s", what)
+	}
+}
@@ -0,0 +1,91 @@
+package imap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strings"
+
+	"github.com/emersion/go-message"
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+	"mailassist/internal/domain/email"
+	"mailassist/internal/infrastructure/mailtext"
+)
+
+// parseMessage reads a raw RFC 5322 message. Transfer encodings and charsets
+// are decoded by go-message; text/plain wins over text/html.
+func parseMessage(messageID string, r io.Reader) (*email.Email, error) {
+	mr, err := mail.CreateReader(r)
+	if err != nil && !message.IsUnknownCharset(err) {
+		return nil, fmt.Errorf("parse message %s: %w", messageID, err)
+	}
+	defer mr.Close()
+
+	header := mr.Header
+	subject, _ := header.Subject()
+
+	e := email.NewEmail(messageID, headerText(header, "From"), subject, readBody(mr))
+	e.Headers = make(map[string]string)
+	fields := header.Fields()
+	for fields.Next() {
+		key := textproto.CanonicalMIMEHeaderKey(fields.Key())
+		if _, ok := e.Headers[key]; !ok {
+			e.Headers[key] = headerText(header, key)
+		}
+	}
+	e.MessageID = header.Get("Message-Id")
+	e.References = header.Get("References")
+
+	return e, nil
+}
+
+func readBody(mr *mail.Reader) string {
+	var plain, html string
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !message.IsUnknownCharset(err) {
+			break
+		}
+
+		h, ok := part.Header.(*mail.InlineHeader)
+		if !ok {
+			continue
+		}
+		mediaType, _, _ := h.ContentType()
+
+		switch mediaType {
+		case "text/plain", "":
+			if plain == "" {
+				b, _ := io.ReadAll(part.Body)
+				plain = strings.TrimSpace(string(b))
+			}
+		case "text/html":
+			if html == "" {
+				b, _ := io.ReadAll(part.Body)
+				html = string(b)
+			}
+		}
+	}
+
+	if plain != "" {
+		return plain
+	}
+	if html != "" {
+		return mailtext.HTMLToText(html)
+	}
+	return ""
+}
+
+// headerText decodes RFC 2047 words, falling back to the raw value
+func headerText(h mail.Header, key string) string {
+	if v, err := h.Text(key); err == nil {
+		return v
+	}
+	return h.Get(key)
+}
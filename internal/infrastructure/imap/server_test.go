@@ -0,0 +1,159 @@
+package imap
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+)
+
+const (
+	testUsername = "username"
+	testPassword = "password"
+	// memory.New seeds INBOX with one message of this UID and a fixed
+	// UIDVALIDITY of 1
+	seededUID      = 6
+	memoryValidity = 1
+)
+
+// testServer is an in-memory IMAP server. Its backend adds MOVE and
+// unilateral updates, which the memory backend lacks.
+type testServer struct {
+	backend *testBackend
+	user    backend.User
+	addr    string
+}
+
+func newTestServer(t *testing.T, mailboxes ...string) *testServer {
+	t.Helper()
+
+	be := &testBackend{Backend: memory.New(), updates: make(chan backend.Update, 16)}
+	user, err := be.Backend.Login(nil, testUsername, testPassword)
+	if err != nil {
+		t.Fatalf("login to memory backend: %v", err)
+	}
+	for _, name := range mailboxes {
+		if err := user.CreateMailbox(name); err != nil {
+			t.Fatalf("create mailbox %q: %v", name, err)
+		}
+	}
+
+	s := server.New(be)
+	s.AllowInsecureAuth = true
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	return &testServer{backend: be, user: user, addr: l.Addr().String()}
+}
+
+// client returns an adapter for the server, closed when the test ends
+func (ts *testServer) client(t *testing.T, labelMode string) *Client {
+	t.Helper()
+	c := NewClient(Config{
+		Addr:      ts.addr,
+		Username:  testUsername,
+		Password:  testPassword,
+		LabelMode: labelMode,
+		Timeout:   5 * time.Second,
+	})
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (ts *testServer) mailbox(t *testing.T, name string) *memory.Mailbox {
+	t.Helper()
+	mbox, err := ts.user.GetMailbox(name)
+	if err != nil {
+		t.Fatalf("get mailbox %q: %v", name, err)
+	}
+	return mbox.(*memory.Mailbox)
+}
+
+// deliver adds a raw message to INBOX and returns its UID
+func (ts *testServer) deliver(t *testing.T, raw string) uint32 {
+	t.Helper()
+	inbox := ts.mailbox(t, "INBOX")
+	body := strings.ReplaceAll(raw, "\n", "\r\n")
+	if err := inbox.CreateMessage(nil, time.Now(), bytes.NewBufferString(body)); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	return inbox.Messages[len(inbox.Messages)-1].Uid
+}
+
+// notify tells clients that have INBOX selected that it changed
+func (ts *testServer) notify(t *testing.T) {
+	t.Helper()
+	status, err := ts.mailbox(t, "INBOX").Status([]imap.StatusItem{imap.StatusMessages})
+	if err != nil {
+		t.Fatalf("inbox status: %v", err)
+	}
+	ts.backend.updates <- &backend.MailboxUpdate{
+		Update:        backend.NewUpdate(testUsername, "INBOX"),
+		MailboxStatus: status,
+	}
+}
+
+func (ts *testServer) message(t *testing.T, mailbox string, uid uint32) *memory.Message {
+	t.Helper()
+	for _, msg := range ts.mailbox(t, mailbox).Messages {
+		if msg.Uid == uid {
+			return msg
+		}
+	}
+	return nil
+}
+
+type testBackend struct {
+	*memory.Backend
+	updates chan backend.Update
+}
+
+func (be *testBackend) Updates() <-chan backend.Update {
+	return be.updates
+}
+
+func (be *testBackend) Login(info *imap.ConnInfo, username, password string) (backend.User, error) {
+	user, err := be.Backend.Login(info, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return &testUser{User: user}, nil
+}
+
+type testUser struct {
+	backend.User
+}
+
+func (u *testUser) GetMailbox(name string) (backend.Mailbox, error) {
+	mbox, err := u.User.GetMailbox(name)
+	if err != nil {
+		return nil, err
+	}
+	return &testMailbox{Mailbox: mbox}, nil
+}
+
+// testMailbox implements MOVE the way servers without it are emulated
+type testMailbox struct {
+	backend.Mailbox
+}
+
+func (m *testMailbox) MoveMessages(uid bool, seqset *imap.SeqSet, dest string) error {
+	if err := m.CopyMessages(uid, seqset, dest); err != nil {
+		return err
+	}
+	if err := m.UpdateMessagesFlags(uid, seqset, imap.AddFlags, []string{imap.DeletedFlag}); err != nil {
+		return err
+	}
+	return m.Expunge()
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mailassist/internal/domain/email"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+)
+
+// AnthropicClient talks to the Anthropic Messages API
+type AnthropicClient struct {
+	apiKey  string
+	baseURL string
+	model   string
+	limiter RequestLimiter
+}
+
+func NewAnthropicClient(cfg Config) (*AnthropicClient, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("ANTHROPIC_API_KEY is not set")
+	}
+
+	baseURL := anthropicBaseURL
+	if cfg.BaseURL != "" {
+		baseURL = cfg.BaseURL
+	}
+
+	return &AnthropicClient{
+		apiKey:  cfg.APIKey,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   cfg.modelOr("claude-3-5-haiku-latest"),
+		limiter: cfg.Limiter,
+	}, nil
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	Messages  []anthropicMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (c *AnthropicClient) Classify(ctx context.Context, subject, body string, examples []email.Example) (*email.Classification, error) {
+	return classify(ctx, limit(c, c.limiter), c.model, subject, body, examples)
+}
+
+func (c *AnthropicClient) complete(ctx context.Context, messages []chatMessage) (string, usage, error) {
+	req := anthropicRequest{
+		Model:     c.model,
+		MaxTokens: 1024,
+	}
+	for _, m := range messages {
+		req.Messages = append(req.Messages, anthropicMessage{Role: m.Role, Content: m.Content})
+	}
+
+	var resp anthropicResponse
+	err := postJSON(ctx, c.baseURL+"/v1/messages", map[string]string{
+		"x-api-key":         c.apiKey,
+		"anthropic-version": anthropicVersion,
+	}, req, &resp)
+	if err != nil {
+		return "", usage{}, fmt.Errorf("anthropic api error: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	if text.Len() == 0 {
+		return "", usage{}, fmt.Errorf("empty LLM response")
+	}
+
+	return text.String(), usage{input: resp.Usage.InputTokens, output: resp.Usage.OutputTokens}, nil
+}
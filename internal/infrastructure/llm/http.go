@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"mailassist/internal/infrastructure/upstream"
+)
+
+var httpClient = &http.Client{Timeout: 2 * time.Minute}
+
+// postJSON sends req as JSON and decodes a 2xx response into resp
+func postJSON(ctx context.Context, url string, headers map[string]string, req, resp any) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return upstream.Classify(err, 0, nil)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	if httpResp.StatusCode/100 != 2 {
+		err := fmt.Errorf("status %d: %s", httpResp.StatusCode, bytes.TrimSpace(body))
+		return upstream.Classify(err, httpResp.StatusCode, httpResp.Header)
+	}
+
+	if err := json.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
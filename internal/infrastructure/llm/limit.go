@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"context"
+
+	emailapp "mailassist/internal/application/email"
+)
+
+// answerTokens reserves room for the model's answer in the token estimate
+const answerTokens = 300
+
+// RequestLimiter throttles requests to the provider. Wait is called before
+// every request, the repair round included, with the request count and the
+// estimated tokens; Observe gets the request's error.
+type RequestLimiter interface {
+	Wait(ctx context.Context, cost, tokens int) error
+	Observe(err error)
+}
+
+// limitedCompleter passes every request through a RequestLimiter
+type limitedCompleter struct {
+	next    completer
+	limiter RequestLimiter
+}
+
+// limit wraps c so each of its requests is throttled by l, if set
+func limit(c completer, l RequestLimiter) completer {
+	if l == nil {
+		return c
+	}
+	return &limitedCompleter{next: c, limiter: l}
+}
+
+func (c *limitedCompleter) complete(ctx context.Context, messages []chatMessage) (string, usage, error) {
+	if err := c.limiter.Wait(ctx, 1, estimateTokens(messages)); err != nil {
+		return "", usage{}, err
+	}
+	text, used, err := c.next.complete(ctx, messages)
+	c.limiter.Observe(err)
+	return text, used, err
+}
+
+// estimateTokens counts the messages and reserves room for the answer
+func estimateTokens(messages []chatMessage) int {
+	contents := make([]string, len(messages))
+	for i, m := range messages {
+		contents[i] = m.Content
+	}
+	return emailapp.EstimateTokens(contents...) + answerTokens
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mailassist/internal/domain/email"
+)
+
+const ollamaBaseURL = "http://localhost:11434"
+
+// OllamaClient talks to a local Ollama server, keeping mail on the machine
+type OllamaClient struct {
+	baseURL string
+	model   string
+	limiter RequestLimiter
+}
+
+func NewOllamaClient(cfg Config) (*OllamaClient, error) {
+	baseURL := ollamaBaseURL
+	if cfg.BaseURL != "" {
+		baseURL = cfg.BaseURL
+	}
+
+	return &OllamaClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   cfg.modelOr("llama3.1"),
+		limiter: cfg.Limiter,
+	}, nil
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Format   any             `json:"format,omitempty"`
+	Stream   bool            `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Message         ollamaMessage `json:"message"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+func (c *OllamaClient) Classify(ctx context.Context, subject, body string, examples []email.Example) (*email.Classification, error) {
+	return classify(ctx, limit(c, c.limiter), c.model, subject, body, examples)
+}
+
+func (c *OllamaClient) complete(ctx context.Context, messages []chatMessage) (string, usage, error) {
+	req := ollamaRequest{
+		Model:  c.model,
+		Format: classificationSchema(),
+	}
+	for _, m := range messages {
+		req.Messages = append(req.Messages, ollamaMessage{Role: m.Role, Content: m.Content})
+	}
+
+	var resp ollamaResponse
+	if err := postJSON(ctx, c.baseURL+"/api/chat", nil, req, &resp); err != nil {
+		return "", usage{}, fmt.Errorf("ollama api error: %w", err)
+	}
+
+	if resp.Message.Content == "" {
+		return "", usage{}, fmt.Errorf("empty LLM response")
+	}
+
+	return resp.Message.Content, usage{input: resp.PromptEvalCount, output: resp.EvalCount}, nil
+}
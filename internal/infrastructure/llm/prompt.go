@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	emailapp "mailassist/internal/application/email"
+	"mailassist/internal/domain/email"
+)
+
+// promptVersion identifies the prompt in the classification history; bump it
+// whenever the prompt changes
+const promptVersion = "3"
+
+// classificationPrompt is shared by every provider so results stay comparable
+func classificationPrompt(subject, body string, examples []email.Example) string {
+	return fmt.Sprintf(`Analyze the following email and return ONLY pure JSON, without markdown and without backticks.
+
+Categories: ["business","private","payments","action_needed","junk","newsletter"]
+
+Labels:
+- if email is Promotions then it's "newsletter"
+- if email is private email then it's "private"
+- if email is Bank/invoices then it's "payments"
+- if email is business offer/linkedIn then it's "business"
+- if email is Junk/spam then it's "junk"
+
+If the email is from a real person and not spam/newsletter/ads/invoices, and requires a response or action, categorize it as "action_needed" and draft a short, polite reply in the language of origin.
+Reply should be a short draft reply only for "action_needed" category with sender name included. For other categories, reply should be empty string.
+Include sender_name in the output, extracted from the email body or subject if possible, otherwise use "there".
+
+Format:
+{"category":"...","label":"...","reply":"...", "sender_name":"..."}
+%s
+Email:
+Subject: %s
+
+Body:
+%s`, emailapp.FormatExamples(examples), subject, body)
+}
+
+// classificationSchema constrains structured output to valid values
+func classificationSchema() map[string]any {
+	categories := make([]string, 0, len(email.Categories()))
+	for _, c := range email.Categories() {
+		categories = append(categories, c.String())
+	}
+	labels := make([]string, 0, len(email.Labels()))
+	for _, l := range email.Labels() {
+		labels = append(labels, l.String())
+	}
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"category":    map[string]any{"type": "string", "enum": categories},
+			"label":       map[string]any{"type": "string", "enum": labels},
+			"reply":       map[string]any{"type": "string"},
+			"sender_name": map[string]any{"type": "string"},
+		},
+		"required":             []string{"category", "label", "reply", "sender_name"},
+		"additionalProperties": false,
+	}
+}
+
+func repairPrompt(err error) string {
+	return fmt.Sprintf(`Your previous answer was rejected: %v.
+Return the corrected answer as pure JSON in the same format, using only the allowed categories and labels.`, err)
+}
+
+type chatMessage struct {
+	Role    string
+	Content string
+}
+
+// usage counts the tokens of one completion
+type usage struct {
+	input, output int
+}
+
+// completer sends a conversation to a provider and returns the reply text
+type completer interface {
+	complete(ctx context.Context, messages []chatMessage) (string, usage, error)
+}
+
+// classify runs the shared prompt against a provider. An invalid answer is
+// sent back to the model once together with the validation error. Latency
+// and token usage cover both rounds.
+func classify(ctx context.Context, c completer, model, subject, body string, examples []email.Example) (*email.Classification, error) {
+	start := time.Now()
+	var total usage
+	stamp := func(classification *email.Classification) *email.Classification {
+		classification.Source = email.SourceLLM
+		classification.Model = model
+		classification.PromptVersion = promptVersion
+		classification.Latency = time.Since(start)
+		classification.InputTokens = total.input
+		classification.OutputTokens = total.output
+		return classification
+	}
+
+	messages := []chatMessage{
+		{Role: "user", Content: classificationPrompt(subject, body, examples)},
+	}
+
+	text, used, err := c.complete(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	total.input += used.input
+	total.output += used.output
+
+	classification, err := parseClassification(text)
+	if err == nil {
+		return stamp(classification), nil
+	}
+
+	log.Printf("Invalid LLM response, retrying with repair prompt: %v", err)
+
+	messages = append(messages,
+		chatMessage{Role: "assistant", Content: text},
+		chatMessage{Role: "user", Content: repairPrompt(err)},
+	)
+
+	text, used, err = c.complete(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	total.input += used.input
+	total.output += used.output
+
+	classification, err = parseClassification(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LLM response after repair: %w", err)
+	}
+
+	return stamp(classification), nil
+}
+
+type llmResponse struct {
+	Category   string `json:"category"`
+	Label      string `json:"label"`
+	Reply      string `json:"reply"`
+	SenderName string `json:"sender_name"`
+}
+
+// parseClassification parses and validates a model reply, tolerating
+// markdown fences
+func parseClassification(text string) (*email.Classification, error) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	var llmResp llmResponse
+	if err := json.Unmarshal([]byte(text), &llmResp); err != nil {
+		log.Printf("LLM parse error: %v (raw=%s)", err, text)
+		return nil, fmt.Errorf("cannot parse JSON: %w", err)
+	}
+
+	classification := email.NewClassification(
+		email.Category(llmResp.Category),
+		email.Label(llmResp.Label),
+		llmResp.Reply,
+		llmResp.SenderName,
+	)
+	if err := classification.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Only action_needed emails get a draft
+	if classification.Category != email.CategoryActionNeeded {
+		classification.Reply = ""
+	}
+
+	return classification, nil
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	emailapp "mailassist/internal/application/email"
+)
+
+// Config selects and configures an LLM provider
+type Config struct {
+	Provider string
+	APIKey   string
+	BaseURL  string
+	Model    string
+	// Limiter, when set, throttles every request to the provider
+	Limiter RequestLimiter
+}
+
+func (c Config) modelOr(defaultModel string) string {
+	if c.Model != "" {
+		return c.Model
+	}
+	return defaultModel
+}
+
+// Factory builds a classifier for one provider
+type Factory func(cfg Config) (emailapp.LLMClassifier, error)
+
+var providers = map[string]Factory{
+	"openai": func(cfg Config) (emailapp.LLMClassifier, error) {
+		return NewOpenAIClient(cfg)
+	},
+	"anthropic": func(cfg Config) (emailapp.LLMClassifier, error) {
+		return NewAnthropicClient(cfg)
+	},
+	"ollama": func(cfg Config) (emailapp.LLMClassifier, error) {
+		return NewOllamaClient(cfg)
+	},
+}
+
+// Register adds or replaces a provider
+func Register(name string, factory Factory) {
+	providers[strings.ToLower(name)] = factory
+}
+
+// New builds the classifier for cfg.Provider
+func New(cfg Config) (emailapp.LLMClassifier, error) {
+	factory, ok := providers[strings.ToLower(cfg.Provider)]
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q (available: %s)", cfg.Provider, strings.Join(Providers(), ", "))
+	}
+
+	return factory(cfg)
+}
+
+// Providers lists the registered provider names
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
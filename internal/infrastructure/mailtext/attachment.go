@@ -0,0 +1,96 @@
+package mailtext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// MaxAttachmentSize is the largest attachment that is downloaded for text
+// extraction
+const MaxAttachmentSize = 10 << 20
+
+// maxAttachmentText caps the text kept per attachment; the classifier only
+// sees an excerpt anyway
+const maxAttachmentText = 64 << 10
+
+type attachmentKind int
+
+const (
+	kindUnsupported attachmentKind = iota
+	kindPDF
+	kindText
+)
+
+func kindOf(mimeType, filename string) attachmentKind {
+	switch strings.ToLower(mimeType) {
+	case "application/pdf":
+		return kindPDF
+	case "text/plain", "text/csv":
+		return kindText
+	}
+
+	// Mail clients often send application/octet-stream, go by the name
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".pdf":
+		return kindPDF
+	case ".txt", ".csv":
+		return kindText
+	}
+	return kindUnsupported
+}
+
+// CanExtractText reports whether AttachmentText supports the attachment
+func CanExtractText(mimeType, filename string) bool {
+	return kindOf(mimeType, filename) != kindUnsupported
+}
+
+// AttachmentText extracts the text of a PDF, plain text or CSV attachment
+func AttachmentText(mimeType, filename string, data []byte) (string, error) {
+	var text string
+	switch kindOf(mimeType, filename) {
+	case kindPDF:
+		var err error
+		if text, err = pdfText(data); err != nil {
+			return "", fmt.Errorf("extract %s: %w", filename, err)
+		}
+	case kindText:
+		text = strings.ToValidUTF8(string(data), "")
+	default:
+		return "", fmt.Errorf("extract %s: unsupported type %q", filename, mimeType)
+	}
+
+	text = strings.TrimSpace(newlineRun.ReplaceAllString(spaceRun.ReplaceAllString(text, " "), "\n\n"))
+	if len(text) > maxAttachmentText {
+		text = strings.ToValidUTF8(text[:maxAttachmentText], "")
+	}
+	return text, nil
+}
+
+// pdfText returns the text of all pages. The PDF reader panics on some
+// malformed files, which is turned into an error.
+func pdfText(data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	plain, err := r.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(io.LimitReader(plain, maxAttachmentText))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(b), ""), nil
+}
@@ -0,0 +1,64 @@
+// Package mailtext holds message text helpers shared by the mailbox adapters
+package mailtext
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "section": true, "article": true, "header": true, "footer": true,
+}
+
+var (
+	spaceRun   = regexp.MustCompile(`[ \t\f\v\x{00a0}]+`)
+	newlineRun = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToText strips markup and keeps paragraph breaks
+func HTMLToText(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return normalizeText(b.String())
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch tag := string(name); {
+			case tag == "script" || tag == "style" || tag == "head":
+				skip++
+			case blockElements[tag]:
+				b.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch tag := string(name); {
+			case tag == "script" || tag == "style" || tag == "head":
+				if skip > 0 {
+					skip--
+				}
+			case blockElements[tag]:
+				b.WriteString("\n")
+			}
+		}
+	}
+}
+
+func normalizeText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(spaceRun.ReplaceAllString(l, " "))
+	}
+	return strings.TrimSpace(newlineRun.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
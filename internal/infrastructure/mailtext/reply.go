@@ -0,0 +1,35 @@
+package mailtext
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"mailassist/internal/domain/email"
+)
+
+// BuildReply renders an RFC 5322 reply with threading headers. from may be
+// empty when the server fills in the sender, as Gmail does.
+func BuildReply(from string, original *email.Email, body string) string {
+	var b strings.Builder
+
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", original.From)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", original.ReplySubject()))
+	if original.MessageID != "" {
+		fmt.Fprintf(&b, "In-Reply-To: %s\r\n", original.MessageID)
+	}
+	if refs := original.ReplyReferences(); refs != "" {
+		fmt.Fprintf(&b, "References: %s\r\n", refs)
+	}
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+
+	return b.String()
+}
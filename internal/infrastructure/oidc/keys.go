@@ -0,0 +1,159 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GoogleCertsURL serves the keys Google signs ID tokens with
+const GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+const (
+	defaultKeysTTL   = time.Hour
+	minRefreshPeriod = time.Minute
+)
+
+// StaticKeySource serves a fixed set of keys, e.g. locally generated ones
+type StaticKeySource map[string]*rsa.PublicKey
+
+func (s StaticKeySource) PublicKey(_ context.Context, keyID string) (*rsa.PublicKey, error) {
+	key, ok := s[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", keyID)
+	}
+	return key, nil
+}
+
+// JWKSKeySource fetches and caches keys from a JWKS endpoint, honouring the
+// Cache-Control max-age and refetching when an unknown key ID shows up
+type JWKSKeySource struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+}
+
+func NewJWKSKeySource(url string) *JWKSKeySource {
+	return &JWKSKeySource{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *JWKSKeySource) PublicKey(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key, ok := s.keys[keyID]
+	stale := now.After(s.expiresAt)
+	unknownAndRefreshable := !ok && now.Sub(s.fetchedAt) > minRefreshPeriod
+
+	if stale || unknownAndRefreshable {
+		if err := s.refresh(ctx); err != nil {
+			// Keep serving cached keys if the endpoint is briefly down
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		key, ok = s.keys[keyID]
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", keyID)
+	}
+	return key, nil
+}
+
+type jwks struct {
+	Keys []struct {
+		KeyID     string `json:"kid"`
+		KeyType   string `json:"kty"`
+		Modulus   string `json:"n"`
+		Exponent  string `json:"e"`
+		Algorithm string `json:"alg"`
+	} `json:"keys"`
+}
+
+// refresh reloads the key set; the caller must hold s.mu
+func (s *JWKSKeySource) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("build jwks request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set jwks
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.KeyType != "RSA" {
+			continue
+		}
+		pub, err := rsaPublicKey(k.Modulus, k.Exponent)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", k.KeyID, err)
+		}
+		keys[k.KeyID] = pub
+	}
+
+	now := time.Now()
+	s.keys = keys
+	s.fetchedAt = now
+	s.expiresAt = now.Add(maxAge(resp.Header.Get("Cache-Control")))
+
+	return nil
+}
+
+func rsaPublicKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nb),
+		E: int(new(big.Int).SetBytes(eb).Int64()),
+	}, nil
+}
+
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if v, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+				return time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return defaultKeysTTL
+}
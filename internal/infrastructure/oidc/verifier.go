@@ -0,0 +1,148 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clockSkew tolerates small clock differences between Google and us
+const clockSkew = time.Minute
+
+// GoogleIssuers are the issuers of Google-signed ID tokens
+var GoogleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// KeySource resolves the public key that signed a token
+type KeySource interface {
+	PublicKey(ctx context.Context, keyID string) (*rsa.PublicKey, error)
+}
+
+// Claims are the ID token claims we check
+type Claims struct {
+	Issuer        string `json:"iss"`
+	Audience      string `json:"aud"`
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	IssuedAt      int64  `json:"iat"`
+	ExpiresAt     int64  `json:"exp"`
+}
+
+// Verifier validates RS256 OIDC ID tokens for one audience and, optionally,
+// one service account email
+type Verifier struct {
+	keys     KeySource
+	audience string
+	email    string
+	issuers  []string
+	now      func() time.Time
+}
+
+func NewVerifier(keys KeySource, audience, email string) *Verifier {
+	return &Verifier{
+		keys:     keys,
+		audience: audience,
+		email:    email,
+		issuers:  GoogleIssuers,
+		now:      time.Now,
+	}
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Verify checks the signature and claims of a compact-serialized JWT
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed JWT", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if h.Algorithm != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, h.Algorithm)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding: %v", ErrInvalidToken, err)
+	}
+
+	key, err := v.keys.PublicKey(ctx, h.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+
+	if err := v.checkClaims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return &claims, nil
+}
+
+func (v *Verifier) checkClaims(c *Claims) error {
+	now := v.now()
+
+	if !contains(v.issuers, c.Issuer) {
+		return fmt.Errorf("unexpected issuer %q", c.Issuer)
+	}
+	if c.Audience != v.audience {
+		return fmt.Errorf("unexpected audience %q", c.Audience)
+	}
+	if v.email != "" {
+		if c.Email != v.email {
+			return fmt.Errorf("unexpected email %q", c.Email)
+		}
+		if !c.EmailVerified {
+			return fmt.Errorf("email %q is not verified", c.Email)
+		}
+	}
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if c.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(c.IssuedAt, 0)) {
+		return fmt.Errorf("token issued in the future")
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
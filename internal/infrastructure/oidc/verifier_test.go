@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAudience = "https://mailassist.example.com/pubsub/push"
+	testEmail    = "push@project.iam.gserviceaccount.com"
+	testKeyID    = "test-key"
+)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":            "https://accounts.google.com",
+		"aud":            testAudience,
+		"sub":            "1234567890",
+		"email":          testEmail,
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encode segment: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signToken builds an RS256 JWT signed with key
+func signToken(t *testing.T, key *rsa.PrivateKey, header map[string]any, claims map[string]any) string {
+	t.Helper()
+	signingInput := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifierAcceptsValidToken(t *testing.T) {
+	key := newTestKey(t)
+	v := NewVerifier(StaticKeySource{testKeyID: &key.PublicKey}, testAudience, testEmail)
+
+	token := signToken(t, key, map[string]any{"alg": "RS256", "kid": testKeyID}, validClaims())
+
+	claims, err := v.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Email != testEmail || claims.Audience != testAudience {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestVerifierRejectsInvalidTokens(t *testing.T) {
+	key := newTestKey(t)
+	otherKey := newTestKey(t)
+	v := NewVerifier(StaticKeySource{testKeyID: &key.PublicKey}, testAudience, testEmail)
+
+	header := map[string]any{"alg": "RS256", "kid": testKeyID}
+	withClaim := func(name string, value any) string {
+		claims := validClaims()
+		claims[name] = value
+		return signToken(t, key, header, claims)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong audience", withClaim("aud", "https://other.example.com")},
+		{"wrong email", withClaim("email", "someone@example.com")},
+		{"unverified email", withClaim("email_verified", false)},
+		{"wrong issuer", withClaim("iss", "https://evil.example.com")},
+		{"expired", withClaim("exp", time.Now().Add(-time.Hour).Unix())},
+		{"missing expiry", withClaim("exp", 0)},
+		{"not yet valid", withClaim("iat", time.Now().Add(time.Hour).Unix())},
+		{"unknown key id", signToken(t, key, map[string]any{"alg": "RS256", "kid": "other-key"}, validClaims())},
+		{"signed by another key", signToken(t, otherKey, header, validClaims())},
+		{"HS256", signToken(t, key, map[string]any{"alg": "HS256", "kid": testKeyID}, validClaims())},
+		{"alg none", encodeSegment(t, map[string]any{"alg": "none", "kid": testKeyID}) + "." + encodeSegment(t, validClaims()) + "."},
+		{"tampered signature", tamperSignature(signToken(t, key, header, validClaims()))},
+		{"tampered claims", tamperClaims(t, signToken(t, key, header, validClaims()))},
+		{"malformed", "not-a-jwt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tt.token)
+			if err == nil {
+				t.Fatalf("Verify accepted the token: %+v", claims)
+			}
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("error %v does not wrap ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifierWithoutEmailAcceptsAnySigner(t *testing.T) {
+	key := newTestKey(t)
+	v := NewVerifier(StaticKeySource{testKeyID: &key.PublicKey}, testAudience, "")
+
+	claims := validClaims()
+	claims["email"] = "someone@example.com"
+	claims["email_verified"] = false
+	token := signToken(t, key, map[string]any{"alg": "RS256", "kid": testKeyID}, claims)
+
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestVerifierToleratesClockSkew(t *testing.T) {
+	key := newTestKey(t)
+	v := NewVerifier(StaticKeySource{testKeyID: &key.PublicKey}, testAudience, testEmail)
+
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-clockSkew / 2).Unix()
+	token := signToken(t, key, map[string]any{"alg": "RS256", "kid": testKeyID}, claims)
+
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+// tamperSignature flips one bit of the signature
+func tamperSignature(token string) string {
+	i := strings.LastIndex(token, ".")
+	sig, _ := base64.RawURLEncoding.DecodeString(token[i+1:])
+	sig[0] ^= 0x01
+	return token[:i+1] + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// tamperClaims swaps the claims while keeping the original signature
+func tamperClaims(t *testing.T, token string) string {
+	parts := strings.Split(token, ".")
+	claims := validClaims()
+	claims["aud"] = "https://other.example.com"
+	parts[1] = encodeSegment(t, claims)
+	return strings.Join(parts, ".")
+}
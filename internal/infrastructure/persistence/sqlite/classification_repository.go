@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mailassist/internal/domain/email"
+)
+
+// ClassificationRepository keeps the append-only classification history of
+// one account
+type ClassificationRepository struct {
+	db      *sql.DB
+	account string
+}
+
+func NewClassificationRepository(db *sql.DB) *ClassificationRepository {
+	return &ClassificationRepository{db: db, account: defaultAccount}
+}
+
+// ForAccount returns a repository scoped to another account
+func (r *ClassificationRepository) ForAccount(account string) *ClassificationRepository {
+	return &ClassificationRepository{db: r.db, account: account}
+}
+
+func (r *ClassificationRepository) AppendClassification(ctx context.Context, rec *email.ClassificationRecord) error {
+	return appendClassification(ctx, r.db, r.account, rec)
+}
+
+// execer is a database or a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func appendClassification(ctx context.Context, db execer, account string, rec *email.ClassificationRecord) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO classifications
+         (account, gmail_id, category, label, source, model, prompt_version, latency_ms, input_tokens, output_tokens, created_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		account, rec.GmailID, string(rec.Category), string(rec.Label), string(rec.Source),
+		rec.Model, rec.PromptVersion, rec.Latency.Milliseconds(), rec.InputTokens, rec.OutputTokens,
+		rec.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("append classification: %w", err)
+	}
+
+	return nil
+}
+
+// History returns every classification of an email, oldest first
+func (r *ClassificationRepository) History(ctx context.Context, gmailID string) ([]*email.ClassificationRecord, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT gmail_id, category, label, source, COALESCE(model, ''), COALESCE(prompt_version, ''),
+		        COALESCE(latency_ms, 0), COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), created_at
+		 FROM classifications WHERE account = ? AND gmail_id = ? ORDER BY id`,
+		r.account, gmailID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query classifications: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*email.ClassificationRecord
+	for rows.Next() {
+		var (
+			rec                     email.ClassificationRecord
+			category, label, source string
+			latencyMs, createdAt    int64
+		)
+		if err := rows.Scan(&rec.GmailID, &category, &label, &source, &rec.Model, &rec.PromptVersion,
+			&latencyMs, &rec.InputTokens, &rec.OutputTokens, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan classification: %w", err)
+		}
+		rec.Category = email.Category(category)
+		rec.Label = email.Label(label)
+		rec.Source = email.Source(source)
+		rec.Latency = time.Duration(latencyMs) * time.Millisecond
+		rec.CreatedAt = time.Unix(createdAt, 0)
+		history = append(history, &rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query classifications: %w", err)
+	}
+
+	return history, nil
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mailassist/internal/domain/email"
+)
+
+func TestClassificationHistoryOrder(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewClassificationRepository(db)
+	ctx := context.Background()
+
+	llm := email.NewClassification(email.CategoryBusiness, email.LabelBusiness, "", "")
+	llm.Source = email.SourceLLM
+	llm.Model = "model-a"
+	llm.PromptVersion = "3"
+	llm.Latency = 1200 * time.Millisecond
+	llm.InputTokens, llm.OutputTokens = 512, 24
+	manual := email.NewClassification(email.CategoryPayments, email.LabelPayments, "", "")
+	manual.Source = email.SourceManual
+
+	for _, c := range []*email.Classification{llm, manual} {
+		if err := repo.AppendClassification(ctx, email.NewClassificationRecord("m1", c)); err != nil {
+			t.Fatalf("AppendClassification: %v", err)
+		}
+	}
+	// Other emails and accounts stay out of the history
+	if err := repo.AppendClassification(ctx, email.NewClassificationRecord("m2", llm)); err != nil {
+		t.Fatalf("AppendClassification: %v", err)
+	}
+	if err := repo.ForAccount("other@example.com").AppendClassification(ctx, email.NewClassificationRecord("m1", llm)); err != nil {
+		t.Fatalf("AppendClassification: %v", err)
+	}
+
+	history, err := repo.History(ctx, "m1")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history holds %d entries, want 2", len(history))
+	}
+
+	first, second := history[0], history[1]
+	if first.Source != email.SourceLLM || first.Label != email.LabelBusiness {
+		t.Errorf("first = %s %s, want the LLM's business label", first.Source, first.Label)
+	}
+	if first.Model != "model-a" || first.PromptVersion != "3" || first.Latency != 1200*time.Millisecond ||
+		first.InputTokens != 512 || first.OutputTokens != 24 {
+		t.Errorf("first = %+v, LLM details lost", first)
+	}
+	if second.Source != email.SourceManual || second.Label != email.LabelPayments {
+		t.Errorf("second = %s %s, want the manual payments label", second.Source, second.Label)
+	}
+}
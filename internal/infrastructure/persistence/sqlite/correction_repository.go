@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"mailassist/internal/domain/email"
+)
+
+// CorrectionRepository stores the label corrections of one account
+type CorrectionRepository struct {
+	db      *sql.DB
+	account string
+}
+
+func NewCorrectionRepository(db *sql.DB) *CorrectionRepository {
+	return &CorrectionRepository{db: db, account: defaultAccount}
+}
+
+// ForAccount returns a repository scoped to another account
+func (r *CorrectionRepository) ForAccount(account string) *CorrectionRepository {
+	return &CorrectionRepository{db: r.db, account: account}
+}
+
+func (r *CorrectionRepository) SaveCorrection(ctx context.Context, c *email.Correction) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO label_corrections (account, gmail_id, sender, label, added, created_at)
+         VALUES (?, ?, ?, ?, ?, ?)`,
+		r.account, c.GmailID, c.Sender, string(c.Label), c.Added, c.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("save correction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *CorrectionRepository) SenderLabel(ctx context.Context, sender string) (email.Label, bool, error) {
+	var label string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT label FROM label_corrections
+		 WHERE account = ? AND sender = ? AND added = 1
+		 ORDER BY id DESC LIMIT 1`,
+		r.account, sender,
+	).Scan(&label)
+
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("query sender label: %w", err)
+	}
+
+	return email.Label(label), true, nil
+}
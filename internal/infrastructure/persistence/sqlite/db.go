@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// Open opens the SQLite database shared by all repositories and migrates it
+// to the current schema
+func Open(dbPath string) (*sql.DB, error) {
+	db, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// open opens the database without touching the schema. The pragmas are part
+// of the DSN so every pooled connection gets them, not just the first one.
+// Transactions start IMMEDIATE: they all write, and taking the write lock up
+// front lets busy_timeout wait for it instead of failing on the upgrade.
+func open(dbPath string) (*sql.DB, error) {
+	dsn := "file:" + dbPath + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	return db, nil
+}
+
+// defaultAccount is Gmail's alias for the authenticated user. Single-account
+// deployments use it, and rows written before multi-account support belong
+// to it.
+const defaultAccount = "me"
@@ -4,52 +4,38 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"mailassist/internal/domain/email"
-	_ "modernc.org/sqlite"
 )
 
+// EmailRepository stores processed emails of one account
 type EmailRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	account string
 }
 
-func NewEmailRepository(dbPath string) (*EmailRepository, error) {
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
-	}
-
-	db.Exec("PRAGMA journal_mode=WAL;")
-	db.Exec("PRAGMA busy_timeout = 5000;")
-
-	schema := `
-CREATE TABLE IF NOT EXISTS emails (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    gmail_id TEXT UNIQUE NOT NULL,
-    from_addr TEXT,
-    subject TEXT,
-    body TEXT,
-    category TEXT,
-    label TEXT,
-    created_at INTEGER
-);
-`
-	if _, err := db.Exec(schema); err != nil {
-		return nil, fmt.Errorf("create schema: %w", err)
-	}
+func NewEmailRepository(db *sql.DB) *EmailRepository {
+	return &EmailRepository{db: db, account: defaultAccount}
+}
 
-	return &EmailRepository{db: db}, nil
+// ForAccount returns a repository scoped to another account
+func (r *EmailRepository) ForAccount(account string) *EmailRepository {
+	return &EmailRepository{db: r.db, account: account}
 }
 
 func (r *EmailRepository) GetById(ctx context.Context, gmailID string) (*email.Email, error) {
 	var e email.Email
 	var category, label string
+	var createdAt int64
 
 	err := r.db.QueryRowContext(ctx,
-		`SELECT gmail_id, from_addr, subject, body, category, label 
-		 FROM emails WHERE gmail_id = ?`,
-		gmailID,
-	).Scan(&e.GmailID, &e.From, &e.Subject, &e.Body, &category, &label)
+		`SELECT gmail_id, from_addr, subject, body, category, label, COALESCE(created_at, 0),
+		        label_pending, pending_reply
+		 FROM emails WHERE account = ? AND gmail_id = ?`,
+		r.account, gmailID,
+	).Scan(&e.GmailID, &e.From, &e.Subject, &e.Body, &category, &label, &createdAt,
+		&e.LabelPending, &e.PendingReply)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("email not found: %s", gmailID)
@@ -60,31 +46,184 @@ func (r *EmailRepository) GetById(ctx context.Context, gmailID string) (*email.E
 
 	e.Category = email.Category(category)
 	e.Label = email.Label(label)
+	e.CreatedAt = time.Unix(createdAt, 0)
+
+	e.Attachments, err = r.attachments(ctx, gmailID)
+	if err != nil {
+		return nil, err
+	}
 
 	return &e, nil
 }
 
-func (r *EmailRepository) Save(ctx context.Context, e *email.Email) error {
-	_, err := r.db.ExecContext(ctx,
-		`INSERT OR REPLACE INTO emails 
-         (gmail_id, from_addr, subject, body, category, label, created_at)
-         VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		e.GmailID, e.From, e.Subject, e.Body,
-		string(e.Category), string(e.Label), e.CreatedAt.Unix(),
+func (r *EmailRepository) attachments(ctx context.Context, gmailID string) ([]email.Attachment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT attachment_id, filename, mime_type, size
+		 FROM email_attachments WHERE account = ? AND gmail_id = ? ORDER BY id`,
+		r.account, gmailID,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []email.Attachment
+	for rows.Next() {
+		var a email.Attachment
+		if err := rows.Scan(&a.ID, &a.Filename, &a.MimeType, &a.Size); err != nil {
+			return nil, fmt.Errorf("scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query attachments: %w", err)
+	}
+
+	return attachments, nil
+}
 
+// SaveClassified stores the email with its attachment metadata and appends
+// rec to its classification history in one transaction, so a failed save
+// leaves no history behind. Extracted attachment text is not kept.
+func (r *EmailRepository) SaveClassified(ctx context.Context, e *email.Email, rec *email.ClassificationRecord) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if err := appendClassification(ctx, tx, r.account, rec); err != nil {
+			return err
+		}
+		return r.save(ctx, tx, e)
+	})
+}
+
+func (r *EmailRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *EmailRepository) save(ctx context.Context, tx *sql.Tx, e *email.Email) error {
+	// Updates keep created_at, the few-shot examples are ordered by it
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO emails
+         (account, gmail_id, from_addr, subject, body, category, label, created_at, label_pending, pending_reply)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+         ON CONFLICT(account, gmail_id) DO UPDATE SET
+             from_addr = excluded.from_addr, subject = excluded.subject, body = excluded.body,
+             category = excluded.category, label = excluded.label,
+             label_pending = excluded.label_pending, pending_reply = excluded.pending_reply`,
+		r.account, e.GmailID, e.From, e.Subject, e.Body,
+		string(e.Category), string(e.Label), e.CreatedAt.Unix(), e.LabelPending, e.PendingReply,
+	)
 	if err != nil {
 		return fmt.Errorf("save email: %w", err)
 	}
 
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM email_attachments WHERE account = ? AND gmail_id = ?`,
+		r.account, e.GmailID,
+	); err != nil {
+		return fmt.Errorf("save attachments: %w", err)
+	}
+	for _, a := range e.Attachments {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO email_attachments (account, gmail_id, attachment_id, filename, mime_type, size)
+			 VALUES (?, ?, ?, ?, ?, ?)`,
+			r.account, e.GmailID, a.ID, a.Filename, a.MimeType, a.Size,
+		); err != nil {
+			return fmt.Errorf("save attachments: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// FinishLabel records that the email's label was applied, or given up on
+func (r *EmailRepository) FinishLabel(ctx context.Context, gmailID string) error {
+	if _, err := r.db.ExecContext(ctx,
+		`UPDATE emails SET label_pending = 0 WHERE account = ? AND gmail_id = ?`,
+		r.account, gmailID,
+	); err != nil {
+		return fmt.Errorf("finish label: %w", err)
+	}
 	return nil
 }
 
+// FinishDraft clears the pending reply and keeps the draft that was created
+// from it; an empty draft means it was given up on
+func (r *EmailRepository) FinishDraft(ctx context.Context, gmailID, draft string) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE emails SET pending_reply = '' WHERE account = ? AND gmail_id = ?`,
+			r.account, gmailID,
+		); err != nil {
+			return fmt.Errorf("finish draft: %w", err)
+		}
+		if draft == "" {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO drafts (account, gmail_id, body, created_at) VALUES (?, ?, ?, ?)`,
+			r.account, gmailID, draft, time.Now().Unix(),
+		); err != nil {
+			return fmt.Errorf("record draft: %w", err)
+		}
+		return nil
+	})
+}
+
+// ConfirmedEmails returns emails whose latest classification came from the
+// user, through a correction or a rule, newest first
+func (r *EmailRepository) ConfirmedEmails(ctx context.Context, excludeGmailID string, limit int) ([]*email.Email, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT e.gmail_id, e.from_addr, e.subject, e.body, e.category, e.label
+		 FROM emails e
+		 WHERE e.account = ? AND e.gmail_id != ?
+		   AND (SELECT c.source FROM classifications c
+		        WHERE c.account = e.account AND c.gmail_id = e.gmail_id
+		        ORDER BY c.id DESC LIMIT 1) IN (?, ?)
+		 ORDER BY e.created_at DESC
+		 LIMIT ?`,
+		r.account, excludeGmailID, string(email.SourceManual), string(email.SourceRule), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query confirmed emails: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []*email.Email
+	for rows.Next() {
+		var e email.Email
+		var category, label string
+		if err := rows.Scan(&e.GmailID, &e.From, &e.Subject, &e.Body, &category, &label); err != nil {
+			return nil, fmt.Errorf("scan confirmed email: %w", err)
+		}
+		e.Category = email.Category(category)
+		e.Label = email.Label(label)
+		emails = append(emails, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query confirmed emails: %w", err)
+	}
+
+	return emails, nil
+}
+
 func (r *EmailRepository) EmailAlreadyProcessed(ctx context.Context, gmailID string) (bool, error) {
 	var exists int
 	err := r.db.QueryRowContext(ctx,
-		`SELECT 1 FROM emails WHERE gmail_id = ? LIMIT 1`,
-		gmailID,
+		`SELECT 1 FROM emails WHERE account = ? AND gmail_id = ? LIMIT 1`,
+		r.account, gmailID,
 	).Scan(&exists)
 
 	if err == sql.ErrNoRows {
@@ -96,7 +235,3 @@ func (r *EmailRepository) EmailAlreadyProcessed(ctx context.Context, gmailID str
 
 	return true, nil
 }
-
-func (r *EmailRepository) Close() error {
-	return r.db.Close()
-}
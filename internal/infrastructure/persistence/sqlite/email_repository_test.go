@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mailassist/internal/domain/email"
+)
+
+func saveClassified(t *testing.T, repo *EmailRepository, gmailID string, createdAt time.Time, label email.Label, source email.Source) {
+	t.Helper()
+	e := email.NewEmail(gmailID, "billing@example.com", "Invoice", "Please pay")
+	e.CreatedAt = createdAt
+	c := email.NewClassification(email.Category(label), label, "", "")
+	c.Source = source
+	e.Classify(c.Category, c.Label)
+	if err := repo.SaveClassified(context.Background(), e, email.NewClassificationRecord(gmailID, c)); err != nil {
+		t.Fatalf("save %s: %v", gmailID, err)
+	}
+}
+
+func TestCorrectionKeepsCreatedAt(t *testing.T) {
+	repo := NewEmailRepository(openTestDB(t))
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	saveClassified(t, repo, "older", now.Add(-2*time.Hour), email.LabelPayments, email.SourceRule)
+	saveClassified(t, repo, "newer", now.Add(-time.Hour), email.LabelBusiness, email.SourceLLM)
+
+	// The user relabels the newer email, the way RecordCorrectionUseCase does
+	e, err := repo.GetById(ctx, "newer")
+	if err != nil {
+		t.Fatalf("GetById: %v", err)
+	}
+	if !e.CreatedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("CreatedAt = %v, want %v", e.CreatedAt, now.Add(-time.Hour))
+	}
+	c := email.NewClassification(email.CategoryPayments, email.LabelPayments, "", "")
+	c.Source = email.SourceManual
+	e.Classify(c.Category, c.Label)
+	// Lose the timestamp on purpose: an update must not overwrite it
+	e.CreatedAt = time.Time{}
+	if err := repo.SaveClassified(ctx, e, email.NewClassificationRecord("newer", c)); err != nil {
+		t.Fatalf("SaveClassified: %v", err)
+	}
+
+	confirmed, err := repo.ConfirmedEmails(ctx, "", 10)
+	if err != nil {
+		t.Fatalf("ConfirmedEmails: %v", err)
+	}
+	if len(confirmed) != 2 || confirmed[0].GmailID != "newer" || confirmed[1].GmailID != "older" {
+		t.Fatalf("confirmed = %v, want newer then older", ids(confirmed))
+	}
+	if confirmed[0].Label != email.LabelPayments {
+		t.Errorf("label = %s, want the corrected one", confirmed[0].Label)
+	}
+}
+
+func ids(emails []*email.Email) []string {
+	var out []string
+	for _, e := range emails {
+		out = append(out, e.GmailID)
+	}
+	return out
+}
+
+func TestPendingActionsAreFinished(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewEmailRepository(db)
+	ctx := context.Background()
+
+	e := email.NewEmail("m1", "bob@example.com", "Meet?", "Can we meet?")
+	c := email.NewClassification(email.CategoryActionNeeded, email.LabelActionNeeded, "Sure", "Bob")
+	e.Classify(c.Category, c.Label)
+	e.LabelPending = true
+	e.PendingReply = c.Reply
+	if err := repo.SaveClassified(ctx, e, email.NewClassificationRecord("m1", c)); err != nil {
+		t.Fatalf("SaveClassified: %v", err)
+	}
+
+	stored, err := repo.GetById(ctx, "m1")
+	if err != nil {
+		t.Fatalf("GetById: %v", err)
+	}
+	if !stored.LabelPending || stored.PendingReply != "Sure" {
+		t.Fatalf("pending = %v %q, want the label and the reply", stored.LabelPending, stored.PendingReply)
+	}
+
+	if err := repo.FinishLabel(ctx, "m1"); err != nil {
+		t.Fatalf("FinishLabel: %v", err)
+	}
+	if err := repo.FinishDraft(ctx, "m1", "Sure"); err != nil {
+		t.Fatalf("FinishDraft: %v", err)
+	}
+
+	stored, err = repo.GetById(ctx, "m1")
+	if err != nil {
+		t.Fatalf("GetById: %v", err)
+	}
+	if stored.Pending() {
+		t.Errorf("pending = %v %q after finishing", stored.LabelPending, stored.PendingReply)
+	}
+	var drafts int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM drafts WHERE account = ? AND gmail_id = ?`, defaultAccount, "m1").Scan(&drafts); err != nil {
+		t.Fatalf("count drafts: %v", err)
+	}
+	if drafts != 1 {
+		t.Errorf("recorded %d drafts, want 1", drafts)
+	}
+}
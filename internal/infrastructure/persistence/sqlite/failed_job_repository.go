@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FailedJob is a job that ran out of retries or failed permanently
+type FailedJob struct {
+	Account   string
+	GmailID   string
+	Attempts  int
+	LastError string
+	Transient bool
+	FailedAt  time.Time
+}
+
+// FailedJobRepository is the dead-letter table for email jobs
+type FailedJobRepository struct {
+	db *sql.DB
+}
+
+func NewFailedJobRepository(db *sql.DB) *FailedJobRepository {
+	return &FailedJobRepository{db: db}
+}
+
+func (r *FailedJobRepository) SaveFailedJob(ctx context.Context, account, gmailID string, attempts int, lastErr error, transient bool) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO failed_jobs (account, gmail_id, attempts, last_error, transient, failed_at)
+         VALUES (?, ?, ?, ?, ?, ?)
+         ON CONFLICT(account, gmail_id) DO UPDATE SET
+             attempts = excluded.attempts,
+             last_error = excluded.last_error,
+             transient = excluded.transient,
+             failed_at = excluded.failed_at`,
+		account, gmailID, attempts, lastErr.Error(), transient, time.Now().Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("save failed job: %w", err)
+	}
+
+	return nil
+}
+
+func (r *FailedJobRepository) ListFailedJobs(ctx context.Context) ([]FailedJob, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT account, gmail_id, attempts, last_error, transient, failed_at
+         FROM failed_jobs ORDER BY failed_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list failed jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []FailedJob
+	for rows.Next() {
+		var j FailedJob
+		var failedAt int64
+		if err := rows.Scan(&j.Account, &j.GmailID, &j.Attempts, &j.LastError, &j.Transient, &failedAt); err != nil {
+			return nil, fmt.Errorf("scan failed job: %w", err)
+		}
+		j.FailedAt = time.Unix(failedAt, 0)
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Requeue moves failed jobs back into the job queue. An empty gmailID
+// requeues every failed job; a gmailID matches it in every account.
+func (r *FailedJobRepository) Requeue(ctx context.Context, gmailID string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SQLite needs a WHERE clause before ON CONFLICT in INSERT ... SELECT
+	const where = ` WHERE (? = '' OR gmail_id = ?)`
+
+	now := time.Now()
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO job_queue (account, gmail_id, visible_at, created_at)
+         SELECT account, gmail_id, ?, ? FROM failed_jobs`+where+`
+         ON CONFLICT(account, gmail_id) DO NOTHING`,
+		now.UnixMilli(), now.Unix(), gmailID, gmailID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("requeue jobs: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM failed_jobs`+where, gmailID, gmailID); err != nil {
+		return 0, fmt.Errorf("delete failed jobs: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+
+	return res.RowsAffected()
+}
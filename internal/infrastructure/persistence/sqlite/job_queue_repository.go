@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	emailapp "mailassist/internal/application/email"
+)
+
+// JobQueueRepository is a durable job queue. Leased jobs stay invisible until
+// their visibility timeout passes, so work held by a crashed worker is
+// picked up again. Jobs of all accounts share the queue.
+type JobQueueRepository struct {
+	db *sql.DB
+}
+
+func NewJobQueueRepository(db *sql.DB) *JobQueueRepository {
+	return &JobQueueRepository{db: db}
+}
+
+// Enqueue adds a job; a job already waiting for the same email is kept
+func (r *JobQueueRepository) Enqueue(ctx context.Context, account, gmailID string) error {
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO job_queue (account, gmail_id, visible_at, created_at)
+         VALUES (?, ?, ?, ?)
+         ON CONFLICT(account, gmail_id) DO NOTHING`,
+		account, gmailID, now.UnixMilli(), now.Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("enqueue job: %w", err)
+	}
+
+	return nil
+}
+
+// Lease takes a visible job and hides it for the visibility timeout. Jobs of
+// the account with the fewest jobs in flight go first, so one busy mailbox
+// cannot hold every worker. It returns nil when no job is available.
+func (r *JobQueueRepository) Lease(ctx context.Context, visibility time.Duration) (*emailapp.Job, error) {
+	token, err := newLeaseToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := emailapp.Job{LeaseToken: token}
+
+	err = r.db.QueryRowContext(ctx,
+		`UPDATE job_queue
+         SET visible_at = ?, lease_token = ?, attempts = attempts + 1
+         WHERE id = (
+             SELECT j.id FROM job_queue j
+             WHERE j.visible_at <= ?
+             ORDER BY (
+                 SELECT COUNT(*) FROM job_queue l
+                 WHERE l.account = j.account AND l.lease_token IS NOT NULL AND l.visible_at > ?
+             ), j.visible_at, j.id
+             LIMIT 1
+         )
+         RETURNING id, account, gmail_id, attempts`,
+		now.Add(visibility).UnixMilli(), token, now.UnixMilli(), now.UnixMilli(),
+	).Scan(&job.ID, &job.Account, &job.GmailID, &job.Attempt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lease job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// Extend pushes the visibility timeout of a job that is still being
+// processed. It reports false when the lease was lost to another worker.
+func (r *JobQueueRepository) Extend(ctx context.Context, job *emailapp.Job, visibility time.Duration) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE job_queue SET visible_at = ? WHERE id = ? AND lease_token = ?`,
+		time.Now().Add(visibility).UnixMilli(), job.ID, job.LeaseToken,
+	)
+	if err != nil {
+		return false, fmt.Errorf("extend job lease: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("extend job lease: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// Ack removes a finished job. A job whose lease expired and was taken by
+// another worker is left alone.
+func (r *JobQueueRepository) Ack(ctx context.Context, job *emailapp.Job) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM job_queue WHERE id = ? AND lease_token = ?`,
+		job.ID, job.LeaseToken,
+	)
+
+	if err != nil {
+		return fmt.Errorf("ack job: %w", err)
+	}
+
+	return nil
+}
+
+// Nack makes a failed job visible again after delay
+func (r *JobQueueRepository) Nack(ctx context.Context, job *emailapp.Job, delay time.Duration, lastErr error) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE job_queue
+         SET visible_at = ?, lease_token = NULL, last_error = ?
+         WHERE id = ? AND lease_token = ?`,
+		time.Now().Add(delay).UnixMilli(), lastErr.Error(), job.ID, job.LeaseToken,
+	)
+
+	if err != nil {
+		return fmt.Errorf("nack job: %w", err)
+	}
+
+	return nil
+}
+
+// Release returns an interrupted job without counting the attempt
+func (r *JobQueueRepository) Release(ctx context.Context, job *emailapp.Job) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE job_queue
+         SET visible_at = ?, lease_token = NULL, attempts = MAX(attempts - 1, 0)
+         WHERE id = ? AND lease_token = ?`,
+		time.Now().UnixMilli(), job.ID, job.LeaseToken,
+	)
+
+	if err != nil {
+		return fmt.Errorf("release job: %w", err)
+	}
+
+	return nil
+}
+
+func newLeaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate lease token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestJobQueueConcurrentWorkers(t *testing.T) {
+	queue := NewJobQueueRepository(openTestDB(t))
+	ctx := context.Background()
+
+	const (
+		workers = 8
+		jobs    = 50
+	)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done = make(map[string]int)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < jobs; i++ {
+				if err := queue.Enqueue(ctx, "me", fmt.Sprintf("w%d-%d", w, i)); err != nil {
+					t.Errorf("enqueue: %v", err)
+					return
+				}
+				job, err := queue.Lease(ctx, time.Minute)
+				if err != nil {
+					t.Errorf("lease: %v", err)
+					return
+				}
+				if job == nil {
+					continue
+				}
+				if err := queue.Ack(ctx, job); err != nil {
+					t.Errorf("ack: %v", err)
+					return
+				}
+				mu.Lock()
+				done[job.GmailID]++
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Drain what the workers did not get to
+	for {
+		job, err := queue.Lease(ctx, time.Minute)
+		if err != nil {
+			t.Fatalf("lease: %v", err)
+		}
+		if job == nil {
+			break
+		}
+		if err := queue.Ack(ctx, job); err != nil {
+			t.Fatalf("ack: %v", err)
+		}
+		done[job.GmailID]++
+	}
+
+	if len(done) != workers*jobs {
+		t.Errorf("processed %d distinct jobs, want %d", len(done), workers*jobs)
+	}
+	for id, n := range done {
+		if n != 1 {
+			t.Errorf("job %s leased %d times", id, n)
+		}
+	}
+}
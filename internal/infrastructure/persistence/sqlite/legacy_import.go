@@ -0,0 +1,189 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNotLegacyStore is returned when asked to import a database that does
+// not have the legacy store layout
+var ErrNotLegacyStore = errors.New("database does not have the legacy store layout")
+
+// LegacyImportReport summarizes an ImportLegacyStore run
+type LegacyImportReport struct {
+	Emails  int
+	Drafts  int
+	Skipped []LegacySkippedRow
+}
+
+// LegacySkippedRow is a legacy email that could not be converted. It is kept
+// in the legacy_emails table.
+type LegacySkippedRow struct {
+	ID      int64
+	GmailID string
+	Reason  string
+}
+
+type legacyEmail struct {
+	id                                                     int64
+	account, gmailID, from, subject, body, category, label sql.NullString
+	draft                                                  sql.NullString
+	createdAt                                              sql.NullInt64
+}
+
+// ImportLegacyStore converts a database created by the legacy store package.
+// Its emails move into the current schema, stored drafts into the drafts
+// table. Rows without a Gmail ID cannot be converted and stay behind in
+// legacy_emails; the table is dropped once it is empty. With dryRun nothing
+// is written and the report shows what would happen.
+func ImportLegacyStore(ctx context.Context, dbPath string, dryRun bool) (*LegacyImportReport, error) {
+	db, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+	defer tx.Rollback()
+
+	legacy, err := isLegacyStore(tx)
+	if err != nil {
+		return nil, err
+	}
+	if !legacy {
+		return nil, ErrNotLegacyStore
+	}
+	columns, err := tableColumns(tx, "emails")
+	if err != nil {
+		return nil, err
+	}
+
+	// Move the legacy table aside so the migrations create the current one.
+	// Indexes move with the table and would clash with the new ones.
+	if _, err := tx.ExecContext(ctx, `
+DROP INDEX IF EXISTS idx_emails_account_gmail_id;
+ALTER TABLE emails RENAME TO legacy_emails;`); err != nil {
+		return nil, fmt.Errorf("move legacy emails: %w", err)
+	}
+
+	if _, err := migrate(tx); err != nil {
+		return nil, err
+	}
+
+	rows, err := readLegacyEmails(ctx, tx, columns["account"])
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LegacyImportReport{}
+	for _, row := range rows {
+		reason, err := importLegacyEmail(ctx, tx, row)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			report.Skipped = append(report.Skipped, LegacySkippedRow{ID: row.id, GmailID: row.gmailID.String, Reason: reason})
+			continue
+		}
+
+		report.Emails++
+		if row.draft.String != "" {
+			report.Drafts++
+		}
+	}
+
+	if len(report.Skipped) == 0 {
+		if _, err := tx.ExecContext(ctx, `DROP TABLE legacy_emails`); err != nil {
+			return nil, fmt.Errorf("drop legacy emails: %w", err)
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	return report, nil
+}
+
+// readLegacyEmails loads the whole legacy table; it is small and the rows
+// are rewritten while converting
+func readLegacyEmails(ctx context.Context, tx *sql.Tx, hasAccount bool) ([]legacyEmail, error) {
+	account := "NULL"
+	if hasAccount {
+		// The current repositories may have added the column to the shared table
+		account = "account"
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, %s, gmail_id, from_addr, subject, body, category, label, draft, created_at
+		 FROM legacy_emails ORDER BY id`, account))
+	if err != nil {
+		return nil, fmt.Errorf("read legacy emails: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []legacyEmail
+	for rows.Next() {
+		var e legacyEmail
+		if err := rows.Scan(&e.id, &e.account, &e.gmailID, &e.from, &e.subject, &e.body,
+			&e.category, &e.label, &e.draft, &e.createdAt); err != nil {
+			return nil, fmt.Errorf("read legacy emails: %w", err)
+		}
+		emails = append(emails, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read legacy emails: %w", err)
+	}
+
+	return emails, nil
+}
+
+// importLegacyEmail copies one row and its draft and removes it from the
+// legacy table. It returns why the row was skipped, if it was.
+func importLegacyEmail(ctx context.Context, tx *sql.Tx, e legacyEmail) (string, error) {
+	if e.gmailID.String == "" {
+		return "missing gmail_id", nil
+	}
+
+	account := e.account.String
+	if account == "" {
+		account = defaultAccount
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO emails (account, gmail_id, from_addr, subject, body, category, label, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (account, gmail_id) DO NOTHING`,
+		account, e.gmailID.String, e.from.String, e.subject.String, e.body.String,
+		e.category.String, e.label.String, e.createdAt.Int64,
+	)
+	if err != nil {
+		return "", fmt.Errorf("import email %d: %w", e.id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "duplicate gmail_id", nil
+	}
+
+	if e.draft.String != "" {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO drafts (account, gmail_id, body, created_at) VALUES (?, ?, ?, ?)`,
+			account, e.gmailID.String, e.draft.String, e.createdAt.Int64,
+		); err != nil {
+			return "", fmt.Errorf("import draft %d: %w", e.id, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM legacy_emails WHERE id = ?`, e.id); err != nil {
+		return "", fmt.Errorf("import email %d: %w", e.id, err)
+	}
+
+	return "", nil
+}
@@ -0,0 +1,202 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrLegacyStore is returned for databases created by the legacy store
+// package, whose emails table still carries drafts. ImportLegacyStore
+// converts them.
+var ErrLegacyStore = errors.New("database was created by the legacy store package, convert it with the importlegacy command")
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations returns the embedded migrations ordered by version. Files
+// are named <version>_<name>.sql.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version prefix", entry.Name())
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %d_%s: versions must be consecutive from 1", m.version, m.name)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Migrate brings the schema up to date. Pending migrations run in one
+// transaction, so a failure leaves the database as it was. A database with a
+// newer schema than this binary knows is refused.
+func Migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer tx.Rollback()
+
+	applied, err := migrate(tx)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	for _, m := range applied {
+		log.Printf("Applied schema migration %d_%s", m.version, m.name)
+	}
+
+	return nil
+}
+
+// migrate applies the pending migrations within tx and returns them
+func migrate(tx *sql.Tx) ([]migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at INTEGER NOT NULL
+);`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return nil, fmt.Errorf("read schema version: %w", err)
+	}
+
+	latest := len(migrations)
+	if current > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than this binary supports (%d)", current, latest)
+	}
+
+	if current == 0 {
+		if err := adoptUnversioned(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := migrations[current:]
+	for _, m := range pending {
+		if _, err := tx.Exec(m.sql); err != nil {
+			return nil, fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.version, m.name, time.Now().Unix(),
+		); err != nil {
+			return nil, fmt.Errorf("record migration %d_%s: %w", m.version, m.name, err)
+		}
+	}
+
+	return pending, nil
+}
+
+// adoptUnversioned prepares a database created before versioned migrations
+// for the baseline: tables from before multi-account support get their
+// account column. Databases of the legacy store package are refused, their
+// drafts would be lost.
+func adoptUnversioned(tx *sql.Tx) error {
+	legacy, err := isLegacyStore(tx)
+	if err != nil {
+		return err
+	}
+	if legacy {
+		return ErrLegacyStore
+	}
+
+	for _, table := range []string{"emails", "rule_hits", "job_queue", "failed_jobs"} {
+		columns, err := tableColumns(tx, table)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 || columns["account"] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN account TEXT NOT NULL DEFAULT '%s'", table, defaultAccount)); err != nil {
+			return fmt.Errorf("add %s.account: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// isLegacyStore reports whether the emails table has the legacy store layout
+func isLegacyStore(tx *sql.Tx) (bool, error) {
+	columns, err := tableColumns(tx, "emails")
+	if err != nil {
+		return false, err
+	}
+	return columns["draft"], nil
+}
+
+// tableColumns returns the column names of a table, none if it does not exist
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("inspect %s: %w", table, err)
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", table, err)
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,90 @@
+-- Schema as it was before versioned migrations. IF NOT EXISTS lets databases
+-- created by the old per-repository setup adopt it.
+
+CREATE TABLE IF NOT EXISTS emails (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    from_addr TEXT,
+    subject TEXT,
+    body TEXT,
+    category TEXT,
+    label TEXT,
+    created_at INTEGER
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_emails_account_gmail_id ON emails (account, gmail_id);
+
+CREATE TABLE IF NOT EXISTS email_attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    attachment_id TEXT,
+    filename TEXT,
+    mime_type TEXT,
+    size INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_attachments_account_gmail_id ON email_attachments (account, gmail_id);
+
+CREATE TABLE IF NOT EXISTS rule_hits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    rule_name TEXT NOT NULL,
+    category TEXT,
+    label TEXT,
+    skip_llm INTEGER NOT NULL,
+    created_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_hits_gmail_id ON rule_hits (gmail_id);
+
+CREATE TABLE IF NOT EXISTS sync_state (
+    mailbox TEXT PRIMARY KEY,
+    history_id INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS watch_state (
+    mailbox TEXT PRIMARY KEY,
+    expiration INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS delta_state (
+    mailbox TEXT PRIMARY KEY,
+    delta_link TEXT NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS job_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    visible_at INTEGER NOT NULL,
+    lease_token TEXT,
+    last_error TEXT,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_queue_visible_at ON job_queue (visible_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_queue_account_gmail_id ON job_queue (account, gmail_id);
+CREATE INDEX IF NOT EXISTS idx_job_queue_account_visible_at ON job_queue (account, visible_at);
+
+CREATE TABLE IF NOT EXISTS failed_jobs (
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL,
+    transient INTEGER NOT NULL,
+    failed_at INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_failed_jobs_account_gmail_id ON failed_jobs (account, gmail_id);
+
+CREATE TABLE IF NOT EXISTS processed_notifications (
+    key TEXT PRIMARY KEY,
+    seen_at INTEGER NOT NULL
+);
@@ -0,0 +1,61 @@
+-- Tables created before multi-account support kept UNIQUE(gmail_id), which
+-- rejects the same message ID in two accounts. Rebuild them so only the
+-- (account, gmail_id) indexes remain.
+
+CREATE TABLE emails_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    from_addr TEXT,
+    subject TEXT,
+    body TEXT,
+    category TEXT,
+    label TEXT,
+    created_at INTEGER
+);
+
+INSERT INTO emails_new (id, account, gmail_id, from_addr, subject, body, category, label, created_at)
+SELECT id, account, gmail_id, from_addr, subject, body, category, label, created_at FROM emails;
+
+DROP TABLE emails;
+ALTER TABLE emails_new RENAME TO emails;
+
+CREATE UNIQUE INDEX idx_emails_account_gmail_id ON emails (account, gmail_id);
+
+CREATE TABLE job_queue_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    visible_at INTEGER NOT NULL,
+    lease_token TEXT,
+    last_error TEXT,
+    created_at INTEGER NOT NULL
+);
+
+INSERT INTO job_queue_new (id, account, gmail_id, attempts, visible_at, lease_token, last_error, created_at)
+SELECT id, account, gmail_id, attempts, visible_at, lease_token, last_error, created_at FROM job_queue;
+
+DROP TABLE job_queue;
+ALTER TABLE job_queue_new RENAME TO job_queue;
+
+CREATE INDEX idx_job_queue_visible_at ON job_queue (visible_at);
+CREATE UNIQUE INDEX idx_job_queue_account_gmail_id ON job_queue (account, gmail_id);
+CREATE INDEX idx_job_queue_account_visible_at ON job_queue (account, visible_at);
+
+CREATE TABLE failed_jobs_new (
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL,
+    transient INTEGER NOT NULL,
+    failed_at INTEGER NOT NULL
+);
+
+INSERT INTO failed_jobs_new (account, gmail_id, attempts, last_error, transient, failed_at)
+SELECT account, gmail_id, attempts, last_error, transient, failed_at FROM failed_jobs;
+
+DROP TABLE failed_jobs;
+ALTER TABLE failed_jobs_new RENAME TO failed_jobs;
+
+CREATE UNIQUE INDEX idx_failed_jobs_account_gmail_id ON failed_jobs (account, gmail_id);
@@ -0,0 +1,12 @@
+-- Reply drafts stored by the legacy store package, kept apart from the
+-- classification result
+
+CREATE TABLE drafts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at INTEGER
+);
+
+CREATE INDEX idx_drafts_account_gmail_id ON drafts (account, gmail_id);
@@ -0,0 +1,18 @@
+-- Append-only history of every classification result and what produced it
+
+CREATE TABLE classifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    category TEXT NOT NULL,
+    label TEXT NOT NULL,
+    source TEXT NOT NULL,
+    model TEXT,
+    prompt_version TEXT,
+    latency_ms INTEGER,
+    input_tokens INTEGER,
+    output_tokens INTEGER,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_classifications_account_gmail_id ON classifications (account, gmail_id);
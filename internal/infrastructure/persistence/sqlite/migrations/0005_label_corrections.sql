@@ -0,0 +1,14 @@
+-- Labels the user added to or removed from emails the assistant classified
+
+CREATE TABLE label_corrections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account TEXT NOT NULL DEFAULT 'me',
+    gmail_id TEXT NOT NULL,
+    sender TEXT NOT NULL,
+    label TEXT NOT NULL,
+    added INTEGER NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_label_corrections_account_gmail_id ON label_corrections (account, gmail_id);
+CREATE INDEX idx_label_corrections_account_sender ON label_corrections (account, sender);
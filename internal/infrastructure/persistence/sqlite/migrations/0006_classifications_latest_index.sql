@@ -0,0 +1,5 @@
+-- Lets the newest classification of an email be found from the index alone,
+-- as the few-shot example query does for every candidate
+
+CREATE INDEX idx_classifications_account_gmail_id_id ON classifications (account, gmail_id, id);
+DROP INDEX idx_classifications_account_gmail_id;
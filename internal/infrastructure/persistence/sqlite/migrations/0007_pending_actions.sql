@@ -0,0 +1,6 @@
+-- Mailbox changes a stored classification still has to make: the label and
+-- the reply draft. Rows written before are done. Drafts created from the
+-- pending reply are kept in the drafts table.
+
+ALTER TABLE emails ADD COLUMN label_pending INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE emails ADD COLUMN pending_reply TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// NotificationRepository persists handled Pub/Sub notification IDs
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// Claim records key as seen at unless it was already seen after since. The
+// insert and the check are one statement, so concurrent claims of one key
+// cannot both succeed.
+func (r *NotificationRepository) Claim(ctx context.Context, key string, at, since time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO processed_notifications (key, seen_at) VALUES (?, ?)
+         ON CONFLICT(key) DO UPDATE SET seen_at = excluded.seen_at
+         WHERE processed_notifications.seen_at < ?`,
+		key, at.Unix(), since.Unix(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("claim notification: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("claim notification: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func (r *NotificationRepository) Forget(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM processed_notifications WHERE key = ?`,
+		key,
+	)
+
+	if err != nil {
+		return fmt.Errorf("forget notification: %w", err)
+	}
+
+	return nil
+}
+
+func (r *NotificationRepository) PruneBefore(ctx context.Context, before time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM processed_notifications WHERE seen_at < ?`,
+		before.Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("prune notifications: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mailassist/internal/domain/rule"
+)
+
+// RuleHitRepository records which rule classified which email
+type RuleHitRepository struct {
+	db      *sql.DB
+	account string
+}
+
+func NewRuleHitRepository(db *sql.DB) *RuleHitRepository {
+	return &RuleHitRepository{db: db, account: defaultAccount}
+}
+
+// ForAccount returns a repository scoped to another account
+func (r *RuleHitRepository) ForAccount(account string) *RuleHitRepository {
+	return &RuleHitRepository{db: r.db, account: account}
+}
+
+func (r *RuleHitRepository) SaveRuleHit(ctx context.Context, gmailID string, hit *rule.Rule) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO rule_hits (account, gmail_id, rule_name, category, label, skip_llm, created_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		r.account, gmailID, hit.Name, string(hit.Category), string(hit.Label), hit.SkipLLM, time.Now().Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("save rule hit: %w", err)
+	}
+
+	return nil
+}
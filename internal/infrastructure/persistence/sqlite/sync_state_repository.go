@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SyncStateRepository stores the last processed Gmail historyId, the
+// expiration of the active Gmail watch and the Graph delta link, per account
+type SyncStateRepository struct {
+	db      *sql.DB
+	account string
+}
+
+func NewSyncStateRepository(db *sql.DB) *SyncStateRepository {
+	return &SyncStateRepository{db: db, account: defaultAccount}
+}
+
+// ForAccount returns a repository scoped to another account
+func (r *SyncStateRepository) ForAccount(account string) *SyncStateRepository {
+	return &SyncStateRepository{db: r.db, account: account}
+}
+
+// LoadHistoryID returns the stored checkpoint, or 0 when none exists yet
+func (r *SyncStateRepository) LoadHistoryID(ctx context.Context) (uint64, error) {
+	var historyID int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT history_id FROM sync_state WHERE mailbox = ?`,
+		r.account,
+	).Scan(&historyID)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("load history id: %w", err)
+	}
+
+	return uint64(historyID), nil
+}
+
+// SaveHistoryID stores the checkpoint
+func (r *SyncStateRepository) SaveHistoryID(ctx context.Context, historyID uint64) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO sync_state (mailbox, history_id, updated_at)
+         VALUES (?, ?, ?)
+         ON CONFLICT(mailbox) DO UPDATE SET
+             history_id = excluded.history_id,
+             updated_at = excluded.updated_at`,
+		r.account, int64(historyID), time.Now().Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("save history id: %w", err)
+	}
+
+	return nil
+}
+
+// LoadWatchExpiration returns when the active watch expires, or the zero time
+// when no watch has been registered yet
+func (r *SyncStateRepository) LoadWatchExpiration(ctx context.Context) (time.Time, error) {
+	var expiration int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT expiration FROM watch_state WHERE mailbox = ?`,
+		r.account,
+	).Scan(&expiration)
+
+	if err == sql.ErrNoRows || expiration == 0 {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load watch expiration: %w", err)
+	}
+
+	return time.UnixMilli(expiration), nil
+}
+
+// SaveWatchExpiration stores when the active watch expires; the zero time
+// marks the watch as stopped
+func (r *SyncStateRepository) SaveWatchExpiration(ctx context.Context, expiration time.Time) error {
+	var millis int64
+	if !expiration.IsZero() {
+		millis = expiration.UnixMilli()
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO watch_state (mailbox, expiration, updated_at)
+         VALUES (?, ?, ?)
+         ON CONFLICT(mailbox) DO UPDATE SET
+             expiration = excluded.expiration,
+             updated_at = excluded.updated_at`,
+		r.account, millis, time.Now().Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("save watch expiration: %w", err)
+	}
+
+	return nil
+}
+
+// LoadDeltaLink returns the stored Graph delta link, or "" when none exists
+func (r *SyncStateRepository) LoadDeltaLink(ctx context.Context) (string, error) {
+	var link string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT delta_link FROM delta_state WHERE mailbox = ?`,
+		r.account,
+	).Scan(&link)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("load delta link: %w", err)
+	}
+
+	return link, nil
+}
+
+// SaveDeltaLink stores the Graph delta link
+func (r *SyncStateRepository) SaveDeltaLink(ctx context.Context, link string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO delta_state (mailbox, delta_link, updated_at)
+         VALUES (?, ?, ?)
+         ON CONFLICT(mailbox) DO UPDATE SET
+             delta_link = excluded.delta_link,
+             updated_at = excluded.updated_at`,
+		r.account, link, time.Now().Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("save delta link: %w", err)
+	}
+
+	return nil
+}
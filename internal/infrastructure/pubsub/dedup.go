@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// pruneEvery is how many marks pass between purges of the persistent store
+const pruneEvery = 100
+
+// DedupStore persists seen keys so duplicates are recognised across restarts.
+// Claim atomically records key as seen at and reports false when it was
+// already seen after since.
+type DedupStore interface {
+	Claim(ctx context.Context, key string, at, since time.Time) (bool, error)
+	Forget(ctx context.Context, key string) error
+	PruneBefore(ctx context.Context, before time.Time) error
+}
+
+// Dedup remembers recently handled notifications. It is safe for concurrent
+// use and bounded both by age (ttl) and size (maxEntries, least recently
+// seen evicted first).
+type Dedup struct {
+	ttl        time.Duration
+	maxEntries int
+	store      DedupStore
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	marks   int
+}
+
+type dedupEntry struct {
+	key    string
+	seenAt time.Time
+}
+
+// NewDedup creates a dedup cache; store may be nil for memory only
+func NewDedup(ttl time.Duration, maxEntries int, store DedupStore) *Dedup {
+	return &Dedup{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		store:      store,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Claim marks key as being handled and reports whether the caller should
+// handle it, false when key was claimed within the ttl. The check and the
+// mark are one step, so of two concurrent deliveries only one gets true.
+// Release a claim when handling fails so a redelivery is handled again.
+func (d *Dedup) Claim(ctx context.Context, key string) bool {
+	now := time.Now()
+
+	d.mu.Lock()
+	if el, ok := d.entries[key]; ok {
+		entry := el.Value.(*dedupEntry)
+		if now.Sub(entry.seenAt) < d.ttl {
+			d.order.MoveToFront(el)
+			d.mu.Unlock()
+			return false
+		}
+		entry.seenAt = now
+		d.order.MoveToFront(el)
+	} else {
+		d.entries[key] = d.order.PushFront(&dedupEntry{key: key, seenAt: now})
+	}
+	d.evict(now)
+	d.marks++
+	prune := d.marks%pruneEvery == 0
+	d.mu.Unlock()
+
+	if d.store == nil {
+		return true
+	}
+
+	claimed, err := d.store.Claim(ctx, key, now, now.Add(-d.ttl))
+	if err != nil {
+		log.Printf("Dedup store claim failed: %v", err)
+		claimed = true
+	}
+	if prune {
+		if err := d.store.PruneBefore(ctx, now.Add(-d.ttl)); err != nil {
+			log.Printf("Dedup store prune failed: %v", err)
+		}
+	}
+	return claimed
+}
+
+// Release drops the claim on key
+func (d *Dedup) Release(ctx context.Context, key string) {
+	d.mu.Lock()
+	if el, ok := d.entries[key]; ok {
+		d.remove(el)
+	}
+	d.mu.Unlock()
+
+	if d.store == nil {
+		return
+	}
+
+	if err := d.store.Forget(ctx, key); err != nil {
+		log.Printf("Dedup store release failed: %v", err)
+	}
+}
+
+// evict drops expired entries and trims the cache to maxEntries.
+// The caller must hold d.mu.
+func (d *Dedup) evict(now time.Time) {
+	for el := d.order.Back(); el != nil; el = d.order.Back() {
+		entry := el.Value.(*dedupEntry)
+		if now.Sub(entry.seenAt) < d.ttl && d.order.Len() <= d.maxEntries {
+			return
+		}
+		d.remove(el)
+	}
+}
+
+func (d *Dedup) remove(el *list.Element) {
+	delete(d.entries, el.Value.(*dedupEntry).key)
+	d.order.Remove(el)
+}
@@ -0,0 +1,107 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"mailassist/internal/infrastructure/oidc"
+)
+
+// maxPushBody bounds the size of a push request; Gmail notifications are tiny
+const maxPushBody = 64 << 10
+
+// TokenVerifier validates the OIDC bearer token Pub/Sub attaches to pushes
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*oidc.Claims, error)
+}
+
+// pushEnvelope is the body of a Pub/Sub push request
+type pushEnvelope struct {
+	Message struct {
+		Data      []byte `json:"data"`
+		MessageID string `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// PushHandler receives Pub/Sub push deliveries over HTTP. A 2xx response
+// acks the message, anything else makes Pub/Sub redeliver it.
+type PushHandler struct {
+	verifier TokenVerifier
+	dedup    *Dedup
+	handler  func(n *Notification) error
+}
+
+// NewPushHandler creates a push endpoint; verifier may be nil only when the
+// endpoint is not reachable from outside
+func NewPushHandler(verifier TokenVerifier, dedup *Dedup, handler func(n *Notification) error) *PushHandler {
+	return &PushHandler{
+		verifier: verifier,
+		dedup:    dedup,
+		handler:  handler,
+	}
+}
+
+func (h *PushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.verifier != nil {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		if _, err := h.verifier.Verify(r.Context(), token); err != nil {
+			log.Printf("Rejected push request: %v", err)
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPushBody))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+
+	var envelope pushEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "invalid push envelope", http.StatusBadRequest)
+		return
+	}
+
+	notification, err := parseNotification(envelope.Message.Data)
+	if err != nil {
+		// Redelivering would not make it parseable
+		log.Printf("Parse notification error: %v", err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	msgID := envelope.Message.MessageID
+	if msgID != "" && !h.dedup.Claim(r.Context(), msgID) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	log.Printf("New push notification - %s (historyID: %d)", notification.EmailAddress, notification.HistoryID)
+
+	if err := h.handler(notification); err != nil {
+		log.Printf("Handle notification error, redelivering: %v", err)
+		if msgID != "" {
+			h.dedup.Release(context.WithoutCancel(r.Context()), msgID)
+		}
+		http.Error(w, "handler failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
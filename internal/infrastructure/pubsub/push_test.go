@@ -0,0 +1,132 @@
+package pubsub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mailassist/internal/infrastructure/oidc"
+)
+
+const (
+	testAudience = "https://mailassist.example.com/pubsub/push"
+	testEmail    = "push@project.iam.gserviceaccount.com"
+)
+
+type pushTest struct {
+	key     *rsa.PrivateKey
+	handler *PushHandler
+	calls   []*Notification
+}
+
+func newPushTest(t *testing.T) *pushTest {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	pt := &pushTest{key: key}
+	verifier := oidc.NewVerifier(oidc.StaticKeySource{"k1": &key.PublicKey}, testAudience, testEmail)
+	pt.handler = NewPushHandler(verifier, NewDedup(time.Hour, 100, nil), func(n *Notification) error {
+		pt.calls = append(pt.calls, n)
+		return nil
+	})
+	return pt
+}
+
+// token signs an ID token for audience with the test key
+func (pt *pushTest) token(t *testing.T, audience string) string {
+	t.Helper()
+	segment := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("encode segment: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	now := time.Now()
+	signingInput := segment(map[string]any{"alg": "RS256", "kid": "k1"}) + "." + segment(map[string]any{
+		"iss":            "https://accounts.google.com",
+		"aud":            audience,
+		"email":          testEmail,
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	})
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, pt.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (pt *pushTest) push(authorization, messageID string) *httptest.ResponseRecorder {
+	data := base64.StdEncoding.EncodeToString([]byte(`{"emailAddress":"user@example.com","historyId":42}`))
+	body := `{"message":{"data":"` + data + `","messageId":"` + messageID + `"},"subscription":"projects/p/subscriptions/s"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/pubsub/push", strings.NewReader(body))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	pt.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPushHandlerRejectsBadTokens(t *testing.T) {
+	pt := newPushTest(t)
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"missing", ""},
+		{"not bearer", "Basic dXNlcjpwYXNz"},
+		{"garbage", "Bearer not-a-jwt"},
+		{"wrong audience", "Bearer " + pt.token(t, "https://other.example.com")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := pt.push(tt.authorization, "m-"+tt.name)
+			if rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want 401 or 403", rec.Code)
+			}
+		})
+	}
+
+	if len(pt.calls) != 0 {
+		t.Errorf("handler called %d times for rejected pushes", len(pt.calls))
+	}
+}
+
+func TestPushHandlerAcceptsValidToken(t *testing.T) {
+	pt := newPushTest(t)
+	auth := "Bearer " + pt.token(t, testAudience)
+
+	if rec := pt.push(auth, "m1"); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", rec.Code)
+	}
+	if len(pt.calls) != 1 || pt.calls[0].EmailAddress != "user@example.com" || pt.calls[0].HistoryID != 42 {
+		t.Fatalf("calls = %+v", pt.calls)
+	}
+
+	// A redelivery is acked without running the handler again
+	if rec := pt.push(auth, "m1"); rec.Code != http.StatusNoContent {
+		t.Fatalf("redelivery status = %d, want 204", rec.Code)
+	}
+	if len(pt.calls) != 1 {
+		t.Errorf("handler called %d times, want 1", len(pt.calls))
+	}
+}
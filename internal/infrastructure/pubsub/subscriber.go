@@ -19,11 +19,11 @@ type Notification struct {
 type Subscriber struct {
 	client         *pubsub.Client
 	subscriptionID string
-	processedIDs   map[uint64]bool
+	dedup          *Dedup
 }
 
 // NewSubscriber creates a new Pub/Sub subscriber
-func NewSubscriber(ctx context.Context, projectID, subscriptionID string) (*Subscriber, error) {
+func NewSubscriber(ctx context.Context, projectID, subscriptionID string, dedup *Dedup) (*Subscriber, error) {
 	client, err := pubsub.NewClient(ctx, projectID)
 	if err != nil {
 		return nil, fmt.Errorf("create pubsub client: %w", err)
@@ -32,12 +32,15 @@ func NewSubscriber(ctx context.Context, projectID, subscriptionID string) (*Subs
 	return &Subscriber{
 		client:         client,
 		subscriptionID: subscriptionID,
-		processedIDs:   make(map[uint64]bool),
+		dedup:          dedup,
 	}, nil
 }
 
-// Listen starts listening for Pub/Sub messages
-func (s *Subscriber) Listen(ctx context.Context, handler func(historyID uint64)) error {
+// Listen starts listening for Pub/Sub messages. A message is acked only when
+// handler succeeds, otherwise Pub/Sub redelivers it. Redeliveries of a
+// message that is handled or being handled are dropped by its Pub/Sub
+// message ID.
+func (s *Subscriber) Listen(ctx context.Context, handler func(n *Notification) error) error {
 	sub := s.client.Subscription(s.subscriptionID)
 
 	log.Println("Pub/Sub listener started...")
@@ -51,15 +54,19 @@ func (s *Subscriber) Listen(ctx context.Context, handler func(historyID uint64))
 		}
 
 		// Avoid duplicate processing
-		if s.processedIDs[notification.HistoryID] {
+		if !s.dedup.Claim(ctx, m.ID) {
 			m.Ack()
 			return
 		}
 
 		log.Printf("New notification - %s (historyID: %d)", notification.EmailAddress, notification.HistoryID)
 
-		s.processedIDs[notification.HistoryID] = true
-		handler(notification.HistoryID)
+		if err := handler(notification); err != nil {
+			log.Printf("Handle notification error, redelivering: %v", err)
+			s.dedup.Release(context.WithoutCancel(ctx), m.ID)
+			m.Nack()
+			return
+		}
 
 		m.Ack()
 	})
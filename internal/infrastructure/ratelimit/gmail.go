@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+
+	emailapp "mailassist/internal/application/email"
+	"mailassist/internal/domain/email"
+	"mailassist/internal/infrastructure/mailtext"
+)
+
+// Gmail quota units per method, see
+// https://developers.google.com/gmail/api/reference/quota
+const (
+	unitsMessagesGet    = 5
+	unitsAttachmentsGet = 5
+	unitsMessagesModify = 5
+	unitsDraftsCreate   = 10
+	unitsHistoryList    = 2
+	unitsMessagesList   = 5
+	unitsGetProfile     = 1
+)
+
+// unitsPerPage is the cost of one page of each paged list method
+var unitsPerPage = map[string]int{
+	"history.list":  unitsHistoryList,
+	"messages.list": unitsMessagesList,
+}
+
+// GmailMailbox is the part of the Gmail adapter used by the use cases. Paged
+// list calls report every page request to the OnPage hook.
+type GmailMailbox interface {
+	emailapp.GmailService
+	emailapp.AttachmentService
+	emailapp.MailboxHistory
+	emailapp.LabelHistory
+	OnPage(hook func(ctx context.Context, method string) error)
+}
+
+// Gmail limits calls to the Gmail API by per-user quota units
+type Gmail struct {
+	next    GmailMailbox
+	limiter *Limiter
+}
+
+// NewGmail wraps next; paged list calls are charged page by page through
+// next's page hook
+func NewGmail(next GmailMailbox, limiter *Limiter) *Gmail {
+	g := &Gmail{next: next, limiter: limiter}
+	next.OnPage(g.chargePage)
+	return g
+}
+
+func (g *Gmail) chargePage(ctx context.Context, method string) error {
+	return g.limiter.Wait(ctx, unitsPerPage[method], 0)
+}
+
+func (g *Gmail) FetchEmail(ctx context.Context, messageID string) (*email.Email, error) {
+	if err := g.limiter.Wait(ctx, unitsMessagesGet, 0); err != nil {
+		return nil, err
+	}
+	e, err := g.next.FetchEmail(ctx, messageID)
+	g.limiter.Observe(err)
+	return e, err
+}
+
+// AttachmentText only charges for attachments the adapter actually downloads
+func (g *Gmail) AttachmentText(ctx context.Context, messageID string, a *email.Attachment) (string, error) {
+	if a.ID == "" || a.Size > mailtext.MaxAttachmentSize || !mailtext.CanExtractText(a.MimeType, a.Filename) {
+		return g.next.AttachmentText(ctx, messageID, a)
+	}
+	if err := g.limiter.Wait(ctx, unitsAttachmentsGet, 0); err != nil {
+		return "", err
+	}
+	text, err := g.next.AttachmentText(ctx, messageID, a)
+	g.limiter.Observe(err)
+	return text, err
+}
+
+func (g *Gmail) ApplyLabel(ctx context.Context, messageID string, label email.Label) error {
+	if err := g.limiter.Wait(ctx, unitsMessagesModify, 0); err != nil {
+		return err
+	}
+	err := g.next.ApplyLabel(ctx, messageID, label)
+	g.limiter.Observe(err)
+	return err
+}
+
+func (g *Gmail) CreateDraft(ctx context.Context, original *email.Email, body string) error {
+	if err := g.limiter.Wait(ctx, unitsDraftsCreate, 0); err != nil {
+		return err
+	}
+	err := g.next.CreateDraft(ctx, original, body)
+	g.limiter.Observe(err)
+	return err
+}
+
+func (g *Gmail) CurrentHistoryID(ctx context.Context) (uint64, error) {
+	if err := g.limiter.Wait(ctx, unitsGetProfile, 0); err != nil {
+		return 0, err
+	}
+	id, err := g.next.CurrentHistoryID(ctx)
+	g.limiter.Observe(err)
+	return id, err
+}
+
+// StreamNewMessagesSince and the other paged calls are charged page by page
+// through chargePage
+func (g *Gmail) StreamNewMessagesSince(ctx context.Context, historyID uint64, fn func(gmailID string) error) (uint64, error) {
+	id, err := g.next.StreamNewMessagesSince(ctx, historyID, fn)
+	g.limiter.Observe(err)
+	return id, err
+}
+
+func (g *Gmail) StreamLabelChangesSince(ctx context.Context, historyID, untilID uint64, fn func(emailapp.LabelChange) error) error {
+	err := g.next.StreamLabelChangesSince(ctx, historyID, untilID, fn)
+	g.limiter.Observe(err)
+	return err
+}
+
+func (g *Gmail) StreamInboxMessages(ctx context.Context, maxResults int64, fn func(gmailID string) error) error {
+	err := g.next.StreamInboxMessages(ctx, maxResults, fn)
+	g.limiter.Observe(err)
+	return err
+}
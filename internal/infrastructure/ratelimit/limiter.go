@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	emailapp "mailassist/internal/application/email"
+)
+
+// Limiter is a token bucket shared by all workers calling one upstream API.
+// It limits request cost per second and, optionally, tokens per minute, and
+// pauses every caller when the API answers 429 with Retry-After.
+type Limiter struct {
+	requests *rate.Limiter
+	tokens   *rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewLimiter allows costPerSecond request cost units per second and
+// tokensPerMinute tokens per minute. Zero disables the respective limit.
+func NewLimiter(costPerSecond float64, tokensPerMinute int) *Limiter {
+	l := &Limiter{
+		requests: rate.NewLimiter(rate.Inf, 0),
+		tokens:   rate.NewLimiter(rate.Inf, 0),
+	}
+
+	if costPerSecond > 0 {
+		l.requests = rate.NewLimiter(rate.Limit(costPerSecond), int(math.Ceil(costPerSecond)))
+	}
+	if tokensPerMinute > 0 {
+		l.tokens = rate.NewLimiter(rate.Limit(float64(tokensPerMinute)/60), tokensPerMinute)
+	}
+
+	return l
+}
+
+// Wait blocks until a request of the given cost and token count may be sent
+func (l *Limiter) Wait(ctx context.Context, cost, tokens int) error {
+	if err := l.waitPause(ctx); err != nil {
+		return err
+	}
+
+	if err := l.requests.WaitN(ctx, min(cost, max(l.requests.Burst(), 1))); err != nil {
+		return err
+	}
+
+	if tokens > 0 {
+		if err := l.tokens.WaitN(ctx, min(tokens, max(l.tokens.Burst(), 1))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Observe pauses all callers when err carries a Retry-After delay
+func (l *Limiter) Observe(err error) {
+	delay := emailapp.RetryAfter(err)
+	if delay <= 0 {
+		return
+	}
+
+	until := time.Now().Add(delay)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *Limiter) waitPause(ctx context.Context) error {
+	l.mu.Lock()
+	wait := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
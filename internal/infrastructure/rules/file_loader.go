@@ -0,0 +1,109 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+
+	"mailassist/internal/domain/email"
+	"mailassist/internal/domain/rule"
+)
+
+// ruleFile is the on-disk format:
+//
+//	{"rules": [{
+//	    "name": "bank",
+//	    "match": {"domain": "mybank.pl", "subject": "(?i)statement"},
+//	    "category": "payments",
+//	    "label": "payments",
+//	    "skip_llm": true
+//	}]}
+type ruleFile struct {
+	Rules []ruleEntry `json:"rules"`
+}
+
+type ruleEntry struct {
+	Name     string    `json:"name"`
+	Match    matchSpec `json:"match"`
+	Category string    `json:"category"`
+	Label    string    `json:"label"`
+	SkipLLM  bool      `json:"skip_llm"`
+}
+
+type matchSpec struct {
+	Sender  string            `json:"sender"`
+	Domain  string            `json:"domain"`
+	ListID  string            `json:"list_id"`
+	Subject string            `json:"subject"`
+	Headers map[string]string `json:"headers"`
+}
+
+// LoadFile reads rules from a JSON file. A missing file means no rules.
+func LoadFile(path string) (rule.Set, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("No rules file at %s, all emails go to the LLM", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+
+	var f ruleFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse rules: %w", err)
+	}
+
+	set := make(rule.Set, 0, len(f.Rules))
+	for i, entry := range f.Rules {
+		r, err := entry.compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+		set = append(set, r)
+	}
+
+	log.Printf("Loaded %d rule(s) from %s", len(set), path)
+
+	return set, nil
+}
+
+func (e ruleEntry) compile() (*rule.Rule, error) {
+	r := &rule.Rule{
+		Name:     e.Name,
+		Sender:   e.Match.Sender,
+		Domain:   e.Match.Domain,
+		ListID:   e.Match.ListID,
+		Category: email.Category(e.Category),
+		Label:    email.Label(e.Label),
+		SkipLLM:  e.SkipLLM,
+	}
+
+	if e.Match.Subject != "" {
+		re, err := regexp.Compile(e.Match.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("subject regex: %w", err)
+		}
+		r.Subject = re
+	}
+
+	if len(e.Match.Headers) > 0 {
+		r.Headers = make(map[string]*regexp.Regexp, len(e.Match.Headers))
+		for name, pattern := range e.Match.Headers {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("header %s regex: %w", name, err)
+			}
+			r.Headers[name] = re
+		}
+	}
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
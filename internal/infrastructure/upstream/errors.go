@@ -0,0 +1,66 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	emailapp "mailassist/internal/application/email"
+)
+
+// Classify marks err as transient when the upstream API answered with a
+// status worth retrying (429, 5xx) or the request never got an answer
+func Classify(err error, status int, header http.Header) error {
+	if err == nil {
+		return nil
+	}
+	if IsRetryableStatus(status) {
+		return emailapp.Transient(err, RetryAfter(header))
+	}
+	if status == 0 && isNetworkError(err) {
+		return emailapp.Transient(err, 0)
+	}
+	return err
+}
+
+// IsRetryableStatus reports whether an HTTP status is worth retrying
+func IsRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+// RetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func RetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func isNetworkError(err error) bool {
+	// Our own cancellation is not a failure of the upstream API
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
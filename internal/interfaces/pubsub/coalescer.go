@@ -0,0 +1,84 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Coalescer merges bursts of triggers per key into as few runs as possible.
+// At most one run per key is in flight; every trigger arriving meanwhile
+// joins a single follow-up run. Callers wait for the run that covers them,
+// so a successful return means their change has been handled. Runs belong to
+// the service, not to a caller: a caller that gives up only stops waiting.
+type Coalescer struct {
+	ctx context.Context
+	run func(ctx context.Context, key string) error
+
+	mu     sync.Mutex
+	states map[string]*coalesceState
+}
+
+type coalesceState struct {
+	running bool
+	next    *coalescedCall
+}
+
+type coalescedCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewCoalescer runs every run with ctx, which should live as long as the
+// service
+func NewCoalescer(ctx context.Context, run func(ctx context.Context, key string) error) *Coalescer {
+	return &Coalescer{
+		ctx:    ctx,
+		run:    run,
+		states: make(map[string]*coalesceState),
+	}
+}
+
+// Do triggers a run for key and waits until a run started after this call
+// has finished, or until ctx is done
+func (c *Coalescer) Do(ctx context.Context, key string) error {
+	c.mu.Lock()
+	st, ok := c.states[key]
+	if !ok {
+		st = &coalesceState{}
+		c.states[key] = st
+	}
+	if st.next == nil {
+		st.next = &coalescedCall{done: make(chan struct{})}
+	}
+	call := st.next
+	if !st.running {
+		st.running = true
+		go c.loop(key, st)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Coalescer) loop(key string, st *coalesceState) {
+	for {
+		c.mu.Lock()
+		call := st.next
+		if call == nil {
+			st.running = false
+			delete(c.states, key)
+			c.mu.Unlock()
+			return
+		}
+		st.next = nil
+		c.mu.Unlock()
+
+		call.err = c.run(c.ctx, key)
+		close(call.done)
+	}
+}
@@ -2,42 +2,71 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 	"log"
-
-	"mailassist/internal/interfaces/worker"
+	"strings"
 )
 
+// Handler routes mailbox change notifications to the sync of the matching
+// account
 type Handler struct {
-	pool         *worker.Pool
-	gmailFetcher HistoryFetcher
+	syncers   map[string]MailboxSyncer
+	coalescer *Coalescer
 }
 
-type HistoryFetcher interface {
-	FetchNewMessagesSince(ctx context.Context, historyID uint64) ([]string, error)
+// MailboxSyncer pulls everything new since the stored checkpoint
+type MailboxSyncer interface {
+	Execute(ctx context.Context) error
 }
 
-func NewHandler(pool *worker.Pool, gmailFetcher HistoryFetcher) *Handler {
-	return &Handler{
-		pool:         pool,
-		gmailFetcher: gmailFetcher,
+// NewHandler takes one syncer per account, keyed by the account's email
+// address. Syncs run with ctx, so a notification whose caller gives up does
+// not abort the sync other notifications wait for.
+func NewHandler(ctx context.Context, syncers map[string]MailboxSyncer) *Handler {
+	h := &Handler{
+		syncers: make(map[string]MailboxSyncer, len(syncers)),
+	}
+	for account, syncer := range syncers {
+		h.syncers[strings.ToLower(account)] = syncer
 	}
+	h.coalescer = NewCoalescer(ctx, func(ctx context.Context, account string) error {
+		return h.syncers[account].Execute(ctx)
+	})
+	return h
 }
 
-func (h *Handler) HandleNotification(ctx context.Context, historyID uint64) {
-	messageIDs, err := h.gmailFetcher.FetchNewMessagesSince(ctx, historyID)
-	if err != nil {
-		log.Printf("Fetch history error: %v", err)
-		return
+// HandleNotification syncs from the stored checkpoint; the notification's
+// historyId only tells us that something changed. Bursts of notifications for
+// one mailbox are coalesced into a single sync. It returns nil once all new
+// messages are durably enqueued, so the notification can be acked.
+func (h *Handler) HandleNotification(ctx context.Context, mailbox string, historyID uint64) error {
+	account, ok := h.route(mailbox)
+	if !ok {
+		// Redelivery would not help, so the notification is dropped
+		log.Printf("No account configured for %s, ignoring notification", mailbox)
+		return nil
 	}
 
-	if len(messageIDs) == 0 {
-		log.Printf("No new messages in historyID: %d", historyID)
-		return
+	if err := h.coalescer.Do(ctx, account); err != nil {
+		return fmt.Errorf("sync %s after historyID %d: %w", mailbox, historyID, err)
 	}
+	return nil
+}
 
-	log.Printf("Found %d new message(s) in historyID: %d", len(messageIDs), historyID)
+// route finds the account for a mailbox address. A single account receives
+// every notification, since it may be configured as "me" rather than by
+// address.
+func (h *Handler) route(mailbox string) (string, bool) {
+	account := strings.ToLower(mailbox)
+	if _, ok := h.syncers[account]; ok {
+		return account, true
+	}
 
-	for _, msgID := range messageIDs {
-		h.pool.Submit(worker.EmailJob{GmailID: msgID})
+	if len(h.syncers) == 1 {
+		for account := range h.syncers {
+			return account, true
+		}
 	}
+
+	return "", false
 }
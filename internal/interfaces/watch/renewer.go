@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// retryInterval is how long to wait after a failed renewal
+	retryInterval = 5 * time.Minute
+	// minRenewInterval spaces successful renewals, so a renewBefore close to
+	// the watch lifetime cannot renew in a loop
+	minRenewInterval = time.Hour
+)
+
+type WatchService interface {
+	EnableWatch(ctx context.Context, topicName string) (time.Time, error)
+	StopWatch(ctx context.Context) error
+}
+
+type StateRepository interface {
+	LoadWatchExpiration(ctx context.Context) (time.Time, error)
+	SaveWatchExpiration(ctx context.Context, expiration time.Time) error
+}
+
+// Renewer keeps the Gmail watch alive by re-registering it well before it
+// expires (Gmail watches last 7 days)
+type Renewer struct {
+	service     WatchService
+	state       StateRepository
+	topicName   string
+	renewBefore time.Duration
+}
+
+func NewRenewer(service WatchService, state StateRepository, topicName string, renewBefore time.Duration) *Renewer {
+	return &Renewer{
+		service:     service,
+		state:       state,
+		topicName:   topicName,
+		renewBefore: renewBefore,
+	}
+}
+
+// Renew registers the watch and persists its expiration
+func (r *Renewer) Renew(ctx context.Context) (time.Time, error) {
+	expiration, err := r.service.EnableWatch(ctx, r.topicName)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := r.state.SaveWatchExpiration(ctx, expiration); err != nil {
+		return time.Time{}, err
+	}
+
+	log.Printf("Gmail watch renewed, expires at %s", expiration.Format(time.RFC3339))
+
+	return expiration, nil
+}
+
+// Run renews the watch on schedule until ctx is cancelled. Failed renewals are
+// retried; Run returns an error once the watch has expired without renewal,
+// because from then on no notifications arrive.
+func (r *Renewer) Run(ctx context.Context) error {
+	expiration, err := r.state.LoadWatchExpiration(ctx)
+	if err != nil {
+		return fmt.Errorf("load watch expiration: %w", err)
+	}
+
+	var renewedAt time.Time
+	for {
+		next := expiration.Add(-r.renewBefore)
+		if earliest := renewedAt.Add(minRenewInterval); next.Before(earliest) {
+			next = earliest
+		}
+		wait := time.Until(next)
+
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+		}
+
+		renewed, err := r.Renew(ctx)
+		if err == nil {
+			expiration = renewed
+			renewedAt = time.Now()
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if !expiration.IsZero() && time.Now().After(expiration) {
+			return fmt.Errorf("gmail watch expired at %s and could not be renewed: %w",
+				expiration.Format(time.RFC3339), err)
+		}
+
+		log.Printf("ERROR: Gmail watch renewal failed (expires at %s), retrying in %s: %v",
+			expiration.Format(time.RFC3339), retryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// Stop unregisters the watch
+func (r *Renewer) Stop(ctx context.Context) error {
+	if err := r.service.StopWatch(ctx); err != nil {
+		return err
+	}
+
+	return r.state.SaveWatchExpiration(ctx, time.Time{})
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -9,22 +10,57 @@ import (
 	"mailassist/internal/application/email"
 )
 
-type EmailJob struct {
-	GmailID string
+// pollInterval is how often idle workers look for jobs whose retry delay or
+// lease has run out
+const pollInterval = time.Second
+
+// Queue is the durable store the pool leases jobs from
+type Queue interface {
+	Enqueue(ctx context.Context, account, gmailID string) error
+	Lease(ctx context.Context, visibility time.Duration) (*email.Job, error)
+	Extend(ctx context.Context, job *email.Job, visibility time.Duration) (bool, error)
+	Ack(ctx context.Context, job *email.Job) error
+	Nack(ctx context.Context, job *email.Job, delay time.Duration, lastErr error) error
+	Release(ctx context.Context, job *email.Job) error
+}
+
+// FailedJobRepository is the dead-letter store for jobs that cannot succeed
+type FailedJobRepository interface {
+	SaveFailedJob(ctx context.Context, account, gmailID string, attempts int, lastErr error, transient bool) error
 }
 
 type Pool struct {
-	workers int
-	jobs    chan EmailJob
-	useCase *email.ClassifyEmailUseCase
-	wg      sync.WaitGroup
+	workers    int
+	queue      Queue
+	useCases   map[string]*email.ClassifyEmailUseCase
+	failedJobs FailedJobRepository
+	retry      RetryPolicy
+	visibility time.Duration
+	wg         sync.WaitGroup
+
+	// notify wakes idle workers when a job is enqueued
+	notify    chan struct{}
+	quit      chan struct{}
+	closeOnce sync.Once
 }
 
-func NewPool(workers int, useCase *email.ClassifyEmailUseCase) *Pool {
+func NewPool(
+	workers int,
+	queue Queue,
+	useCases map[string]*email.ClassifyEmailUseCase,
+	failedJobs FailedJobRepository,
+	retry RetryPolicy,
+	visibility time.Duration,
+) *Pool {
 	return &Pool{
-		workers: workers,
-		jobs:    make(chan EmailJob, 100),
-		useCase: useCase,
+		workers:    workers,
+		queue:      queue,
+		useCases:   useCases,
+		failedJobs: failedJobs,
+		retry:      retry,
+		visibility: visibility,
+		notify:     make(chan struct{}, workers),
+		quit:       make(chan struct{}),
 	}
 }
 
@@ -37,12 +73,37 @@ func (p *Pool) Start(ctx context.Context) {
 	}
 }
 
-func (p *Pool) Submit(job EmailJob) {
-	p.jobs <- job
+// ForAccount returns the email.JobQueue for one account's jobs
+func (p *Pool) ForAccount(account string) email.JobQueue {
+	return &accountQueue{pool: p, account: account}
+}
+
+type accountQueue struct {
+	pool    *Pool
+	account string
+}
+
+func (q *accountQueue) Enqueue(ctx context.Context, gmailID string) error {
+	return q.pool.Enqueue(ctx, q.account, gmailID)
 }
 
+// Enqueue stores the job durably before returning and wakes an idle worker
+func (p *Pool) Enqueue(ctx context.Context, account, gmailID string) error {
+	if err := p.queue.Enqueue(ctx, account, gmailID); err != nil {
+		return err
+	}
+
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Shutdown waits for running jobs; queued jobs stay in the database
 func (p *Pool) Shutdown() {
-	close(p.jobs)
+	p.closeOnce.Do(func() { close(p.quit) })
 	p.wg.Wait()
 	log.Println("Worker pool shut down")
 }
@@ -54,17 +115,141 @@ func (p *Pool) worker(ctx context.Context, workerID int) {
 		select {
 		case <-ctx.Done():
 			return
-		case job, ok := <-p.jobs:
-			if !ok {
+		case <-p.quit:
+			return
+		default:
+		}
+
+		job, err := p.queue.Lease(ctx, p.visibility)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[worker %d] Lease error: %v", workerID, err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.quit:
 				return
+			case <-p.notify:
+			case <-time.After(pollInterval):
 			}
+			continue
+		}
+
+		p.process(ctx, workerID, job)
+	}
+}
+
+func (p *Pool) process(ctx context.Context, workerID int, job *email.Job) {
+	// The job context may be cancelled during shutdown, queue updates must
+	// still land
+	bg, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A job that keeps crashing its worker never gets to report an error
+	if job.Attempt > p.retry.MaxAttempts {
+		p.deadLetter(bg, job, fmt.Errorf("lease expired %d times", job.Attempt-1), true)
+		return
+	}
+
+	useCase, ok := p.useCases[job.Account]
+	if !ok {
+		p.deadLetter(bg, job, fmt.Errorf("unknown account %q", job.Account), false)
+		return
+	}
 
-			if err := p.useCase.Execute(ctx, job.GmailID); err != nil {
-				log.Printf("[worker %d] Error processing %s: %v", workerID, job.GmailID, err)
+	jobCtx, cancelJob := context.WithCancel(ctx)
+	defer cancelJob()
+
+	stopHeartbeat := p.heartbeat(jobCtx, cancelJob, workerID, job)
+	err := useCase.Execute(jobCtx, job.GmailID)
+	stopHeartbeat()
+
+	if err == nil {
+		if err := p.queue.Ack(bg, job); err != nil {
+			log.Printf("[worker %d] Ack error for %s: %v", workerID, job.GmailID, err)
+		}
+		return
+	}
+
+	if ctx.Err() != nil {
+		if err := p.queue.Release(bg, job); err != nil {
+			log.Printf("[worker %d] Release error for %s: %v", workerID, job.GmailID, err)
+		}
+		return
+	}
+	if jobCtx.Err() != nil {
+		// The lease was lost, the job belongs to another worker now
+		return
+	}
+
+	transient := email.IsTransient(err)
+
+	if transient && job.Attempt < p.retry.MaxAttempts {
+		delay := p.retry.Delay(job.Attempt, email.RetryAfter(err))
+		log.Printf("[worker %d] Transient error processing %s/%s (attempt %d/%d), retrying in %s: %v",
+			workerID, job.Account, job.GmailID, job.Attempt, p.retry.MaxAttempts, delay.Round(time.Millisecond), err)
+		if err := p.queue.Nack(bg, job, delay, err); err != nil {
+			log.Printf("[worker %d] Nack error for %s: %v", workerID, job.GmailID, err)
+		}
+		return
+	}
+
+	log.Printf("[worker %d] Error processing %s/%s (attempt %d): %v", workerID, job.Account, job.GmailID, job.Attempt, err)
+	p.deadLetter(bg, job, err, transient)
+}
+
+// heartbeat extends the job's lease while it runs, so a job that takes
+// longer than the visibility timeout is not handed to a second worker. A lost
+// lease cancels the job. The returned function stops the heartbeat.
+func (p *Pool) heartbeat(ctx context.Context, cancel context.CancelFunc, workerID int, job *email.Job) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(p.visibility / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
 			}
 
-			// Rate limiting
-			time.Sleep(200 * time.Millisecond)
+			ok, err := p.queue.Extend(ctx, job, p.visibility)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[worker %d] Extend error for %s: %v", workerID, job.GmailID, err)
+				}
+				continue
+			}
+			if !ok {
+				log.Printf("[worker %d] Lost lease on %s/%s, abandoning it", workerID, job.Account, job.GmailID)
+				cancel()
+				return
+			}
 		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (p *Pool) deadLetter(ctx context.Context, job *email.Job, lastErr error, transient bool) {
+	if err := p.failedJobs.SaveFailedJob(ctx, job.Account, job.GmailID, job.Attempt, lastErr, transient); err != nil {
+		log.Printf("Failed to record failed job %s: %v", job.GmailID, err)
+		return
+	}
+
+	if err := p.queue.Ack(ctx, job); err != nil {
+		log.Printf("Failed to remove failed job %s from queue: %v", job.GmailID, err)
 	}
 }
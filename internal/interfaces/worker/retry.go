@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how failed jobs are retried
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Delay returns a jittered exponential backoff for the given attempt
+// (1-based), never shorter than the delay the upstream API asked for
+func (r RetryPolicy) Delay(attempt int, retryAfter time.Duration) time.Duration {
+	backoff := r.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > r.MaxDelay {
+		backoff = r.MaxDelay
+	}
+
+	// Full jitter spreads retries of jobs that failed together
+	delay := time.Duration(rand.Int64N(int64(backoff)) + 1)
+
+	if delay < retryAfter {
+		delay = retryAfter
+	}
+	return delay
+}